@@ -0,0 +1,393 @@
+package generictypes
+
+import (
+	"github.com/juju/errgo"
+
+	"encoding/json"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	ErrInvalidPortRange = errgo.New("Invalid port range. Format: <first port>-<last port>")
+	ErrPortConflict     = errgo.New("Port is already reserved")
+	ErrNoFreePort       = errgo.New("No free port")
+)
+
+// PortRange is an inclusive range of port numbers, e.g. 30000-32767.
+type PortRange struct {
+	First uint16
+	Last  uint16
+}
+
+// ParsePortRange parses "<first>-<last>" or a single port like "8080".
+func ParsePortRange(input string) (PortRange, error) {
+	parts := strings.Split(input, "-")
+	if len(parts) > 2 {
+		return PortRange{}, newPortError(input, ErrInvalidPortRange, "Invalid port range %#v", input)
+	}
+
+	var bounds []uint16
+	for _, part := range parts {
+		port, err := ParseDockerPort(part)
+		if err != nil || port.Format() != PortFormatString {
+			return PortRange{}, newPortError(input, ErrInvalidPortRange, "Invalid port range %#v", input)
+		}
+		bounds = append(bounds, port.Number())
+	}
+
+	r := PortRange{First: bounds[0], Last: bounds[len(bounds)-1]}
+	if err := r.Validate(); err != nil {
+		return PortRange{}, err
+	}
+	return r, nil
+}
+
+// Validate checks that the range is not empty and does not contain port 0.
+func (r PortRange) Validate() error {
+	if r.First == 0 || r.First > r.Last {
+		return newPortError(r.String(), ErrInvalidPortRange, "Invalid port range %s", r.String())
+	}
+	return nil
+}
+
+// Contains returns true if the given port number is in the range.
+func (r PortRange) Contains(port uint16) bool {
+	return port >= r.First && port <= r.Last
+}
+
+// Returns the range as <first>-<last>.
+func (r PortRange) String() string {
+	return fmt.Sprintf("%d-%d", r.First, r.Last)
+}
+
+func (r PortRange) MarshalJSON() ([]byte, error) {
+	return json.Marshal(r.String())
+}
+
+func (r *PortRange) UnmarshalJSON(data []byte) error {
+	var input string
+	if err := json.Unmarshal(data, &input); err != nil {
+		return err
+	}
+	parsed, err := ParsePortRange(input)
+	if err != nil {
+		return err
+	}
+	*r = parsed
+	return nil
+}
+
+// PortReservation is a host port reserved by a PortAllocator.
+type PortReservation struct {
+	// HostIP is the IP the port is reserved on, empty for all IPs.
+	HostIP string `json:"HostIp"`
+
+	// Port is the host port and its protocol.
+	Port DockerPort `json:"Port"`
+}
+
+// portAllocatorKey identifies a port independent of the host IP.
+type portAllocatorKey struct {
+	Protocol string
+	Number   uint16
+}
+
+// PortAllocator hands out host ports from configured ranges without double
+// booking them. A port reserved on all IPs conflicts with the same port on any
+// specific IP and vice versa. Ports of different protocols never conflict. A
+// PortAllocator is safe for concurrent use. The zero value has no ranges, so
+// ports can only be reserved, not allocated.
+type PortAllocator struct {
+	mutex  sync.Mutex
+	ranges []PortRange
+
+	// reserved maps ports to the IPs they are reserved on, "" for all IPs.
+	reserved map[portAllocatorKey]map[string]struct{}
+
+	// next is the port number to start searching from per protocol, so that
+	// released ports are not handed out again right away.
+	next map[string]uint16
+}
+
+// NewPortAllocator returns an allocator that allocates from the given
+// ranges, in order.
+func NewPortAllocator(ranges ...PortRange) (*PortAllocator, error) {
+	for _, r := range ranges {
+		if err := r.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	return &PortAllocator{
+		ranges:   append([]PortRange{}, ranges...),
+		reserved: map[portAllocatorKey]map[string]struct{}{},
+		next:     map[string]uint16{},
+	}, nil
+}
+
+// Reserve reserves the given host port on the given IP, or on all IPs if
+// hostIP is empty, "0.0.0.0" or "::". Ports outside of the allocator's ranges
+// can be reserved as well. Returns an error wrapping ErrPortConflict if the
+// port is already reserved on the IP.
+func (a *PortAllocator) Reserve(hostIP string, port DockerPort) error {
+	ip, key, err := portAllocatorArgs(hostIP, port)
+	if err != nil {
+		return err
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if a.conflicts(ip, key) {
+		return newPortError(port.String(), ErrPortConflict, "Port %s is already reserved on %s", port.String(), portAllocatorIPString(ip))
+	}
+	a.reserve(ip, key)
+	return nil
+}
+
+// Release releases the given host port on the given IP and returns false if
+// it was not reserved.
+func (a *PortAllocator) Release(hostIP string, port DockerPort) bool {
+	ip, key, err := portAllocatorArgs(hostIP, port)
+	if err != nil {
+		return false
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if _, ok := a.reserved[key][ip]; !ok {
+		return false
+	}
+	delete(a.reserved[key], ip)
+	if len(a.reserved[key]) == 0 {
+		delete(a.reserved, key)
+	}
+	return true
+}
+
+// Allocate reserves and returns the next free port of the given protocol on
+// the given IP. Ports are handed out in order of the ranges, continuing after
+// the previously allocated port, so that released ports are not reused right
+// away. Returns an error wrapping ErrNoFreePort if all ports of the ranges are
+// reserved.
+func (a *PortAllocator) Allocate(hostIP, protocol string) (DockerPort, error) {
+	ip, err := portAllocatorIP(hostIP)
+	if err != nil {
+		return DockerPort{}, err
+	}
+	if protocol != ProtocolTCP && protocol != ProtocolUDP {
+		return DockerPort{}, newPortError(protocol, ErrInvalidProtocol, "Unknown protocol: '%s'", protocol)
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	number, ok := a.freePort(ip, protocol)
+	if !ok {
+		return DockerPort{}, newPortError(protocol, ErrNoFreePort, "All %s ports are reserved on %s", protocol, portAllocatorIPString(ip))
+	}
+	a.reserve(ip, portAllocatorKey{Protocol: protocol, Number: number})
+	if a.next == nil {
+		a.next = map[string]uint16{}
+	}
+	a.next[protocol] = number + 1
+	return NewDockerPort(number, protocol)
+}
+
+// Reservations returns all reserved ports ordered by number, protocol and IP.
+func (a *PortAllocator) Reservations() []PortReservation {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return a.reservations()
+}
+
+// portAllocatorSnapshot is the JSON representation of a PortAllocator.
+type portAllocatorSnapshot struct {
+	Ranges       []PortRange       `json:"Ranges"`
+	Reservations []PortReservation `json:"Reservations"`
+
+	// Next is the port number Allocate starts searching from per protocol.
+	Next map[string]uint16 `json:"Next,omitempty"`
+}
+
+// MarshalJSON returns a snapshot of the ranges, reservations and allocation
+// cursors, which can be restored with UnmarshalJSON.
+func (a *PortAllocator) MarshalJSON() ([]byte, error) {
+	a.mutex.Lock()
+	snapshot := portAllocatorSnapshot{
+		Ranges:       append([]PortRange{}, a.ranges...),
+		Reservations: append([]PortReservation{}, a.reservations()...),
+		Next:         map[string]uint16{},
+	}
+	for protocol, next := range a.next {
+		snapshot.Next[protocol] = next
+	}
+	a.mutex.Unlock()
+
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return nil, errgo.Mask(err)
+	}
+	return data, nil
+}
+
+// UnmarshalJSON restores a snapshot created by MarshalJSON, replacing all
+// ranges, reservations and allocation cursors. The allocator is left
+// unchanged on errors, e.g. conflicting reservations.
+func (a *PortAllocator) UnmarshalJSON(data []byte) error {
+	var snapshot portAllocatorSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return err
+	}
+
+	restored, err := NewPortAllocator(snapshot.Ranges...)
+	if err != nil {
+		return err
+	}
+	for _, reservation := range snapshot.Reservations {
+		if err := restored.Reserve(reservation.HostIP, reservation.Port); err != nil {
+			return err
+		}
+	}
+	for protocol, next := range snapshot.Next {
+		restored.next[protocol] = next
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.ranges = restored.ranges
+	a.reserved = restored.reserved
+	a.next = restored.next
+	return nil
+}
+
+// conflicts returns true if the port is reserved on the IP, on all IPs, or on
+// any IP if ip is "" (all IPs).
+func (a *PortAllocator) conflicts(ip string, key portAllocatorKey) bool {
+	ips := a.reserved[key]
+	if len(ips) == 0 {
+		return false
+	}
+	if ip == "" {
+		return true
+	}
+	_, all := ips[""]
+	_, same := ips[ip]
+	return all || same
+}
+
+func (a *PortAllocator) reserve(ip string, key portAllocatorKey) {
+	if a.reserved == nil {
+		a.reserved = map[portAllocatorKey]map[string]struct{}{}
+	}
+	if a.reserved[key] == nil {
+		a.reserved[key] = map[string]struct{}{}
+	}
+	a.reserved[key][ip] = struct{}{}
+}
+
+// reservations returns all reserved ports ordered by number, protocol and IP.
+func (a *PortAllocator) reservations() []PortReservation {
+	keys := make([]portAllocatorKey, 0, len(a.reserved))
+	for key := range a.reserved {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Number != keys[j].Number {
+			return keys[i].Number < keys[j].Number
+		}
+		return keys[i].Protocol < keys[j].Protocol
+	})
+
+	var reservations []PortReservation
+	for _, key := range keys {
+		ips := make([]string, 0, len(a.reserved[key]))
+		for ip := range a.reserved[key] {
+			ips = append(ips, ip)
+		}
+		sort.Strings(ips)
+		for _, ip := range ips {
+			reservations = append(reservations, PortReservation{
+				HostIP: ip,
+				Port:   DockerPort{Port: strconv.FormatUint(uint64(key.Number), 10), Protocol: key.Protocol},
+			})
+		}
+	}
+	return reservations
+}
+
+// freePort returns the first port of the protocol that is free on the IP. The
+// search starts at the next port of the protocol and wraps around, so the
+// range containing it is visited twice: from the next port to its end first,
+// and from its beginning up to the next port last.
+func (a *PortAllocator) freePort(ip, protocol string) (uint16, bool) {
+	if len(a.ranges) == 0 {
+		return 0, false
+	}
+	start, next := 0, a.next[protocol]
+	for i, r := range a.ranges {
+		if r.Contains(next) {
+			start = i
+			break
+		}
+	}
+
+	for i := 0; i <= len(a.ranges); i++ {
+		r := a.ranges[(start+i)%len(a.ranges)]
+		first, last := int(r.First), int(r.Last)
+		if i == 0 && r.Contains(next) {
+			first = int(next)
+		}
+		if i == len(a.ranges) {
+			if !r.Contains(next) {
+				break
+			}
+			last = int(next) - 1
+		}
+		for number := first; number <= last; number++ {
+			if !a.conflicts(ip, portAllocatorKey{Protocol: protocol, Number: uint16(number)}) {
+				return uint16(number), true
+			}
+		}
+	}
+	return 0, false
+}
+
+// portAllocatorArgs validates the arguments of Reserve and Release.
+func portAllocatorArgs(hostIP string, port DockerPort) (string, portAllocatorKey, error) {
+	if err := port.Validate(); err != nil {
+		return "", portAllocatorKey{}, err
+	}
+	ip, err := portAllocatorIP(hostIP)
+	if err != nil {
+		return "", portAllocatorKey{}, err
+	}
+	return ip, portAllocatorKey{Protocol: port.Protocol, Number: port.Number()}, nil
+}
+
+// portAllocatorIP returns the IP in canonical form, or "" for all IPs.
+func portAllocatorIP(hostIP string) (string, error) {
+	if hostIP == "" {
+		return "", nil
+	}
+	ip := net.ParseIP(hostIP)
+	if ip == nil {
+		return "", newPortError(hostIP, ErrInvalidPortBinding, "Invalid host IP %#v", hostIP)
+	}
+	if ip.IsUnspecified() {
+		return "", nil
+	}
+	return ip.String(), nil
+}
+
+func portAllocatorIPString(ip string) string {
+	if ip == "" {
+		return "all IPs"
+	}
+	return ip
+}
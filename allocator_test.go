@@ -0,0 +1,285 @@
+package generictypes
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+var portRanges = []struct {
+	Input    string
+	Expected PortRange
+	Error    bool
+}{
+	{"30000-32767", PortRange{30000, 32767}, false},
+	{"8080", PortRange{8080, 8080}, false},
+	{"080-90", PortRange{80, 90}, false},
+	{"", PortRange{}, true},
+	{"0-10", PortRange{}, true},
+	{"90-80", PortRange{}, true},
+	{"80-90-100", PortRange{}, true},
+	{"80/tcp-90/tcp", PortRange{}, true},
+	{"-80", PortRange{}, true},
+	{"80-70000", PortRange{}, true},
+}
+
+func TestParsePortRange(t *testing.T) {
+	for _, data := range portRanges {
+		r, err := ParsePortRange(data.Input)
+		if data.Error {
+			if !errors.Is(err, ErrInvalidPortRange) {
+				t.Fatalf("Expected ErrInvalidPortRange for '%s', got %v", data.Input, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Expected no error for '%s', got %v", data.Input, err)
+		}
+		if r != data.Expected {
+			t.Fatalf("Expected %v for '%s', got %v", data.Expected, data.Input, r)
+		}
+	}
+}
+
+func TestPortAllocator_Conflicts(t *testing.T) {
+	var reservations = []struct {
+		HostIP   string
+		Port     string
+		Conflict bool
+	}{
+		{"127.0.0.1", "8080/tcp", false},
+		{"127.0.0.1", "8080", true},
+		{"10.0.0.1", "8080/tcp", false},
+		{"", "8080/tcp", true},
+		{"0.0.0.0", "8080/tcp", true},
+		{"127.0.0.1", "8080/udp", false},
+		{"", "9090/tcp", false},
+		{"127.0.0.1", "9090/tcp", true},
+		{"::1", "9090/tcp", true},
+		{"::", "9090/tcp", true},
+		{"::1", "8080/tcp", false},
+		{"0:0:0:0:0:0:0:1", "8080/tcp", true},
+	}
+
+	var a PortAllocator
+	for _, data := range reservations {
+		err := a.Reserve(data.HostIP, MustParseDockerPort(data.Port))
+		if data.Conflict != errors.Is(err, ErrPortConflict) {
+			t.Fatalf("Expected conflict %v for %s on '%s', got %v", data.Conflict, data.Port, data.HostIP, err)
+		}
+		if !data.Conflict && err != nil {
+			t.Fatalf("Expected no error for %s on '%s', got %v", data.Port, data.HostIP, err)
+		}
+	}
+
+	if a.Release("127.0.0.1", MustParseDockerPort("9090/tcp")) {
+		t.Fatalf("Expected 9090/tcp not to be reserved on 127.0.0.1")
+	}
+	if !a.Release("0.0.0.0", MustParseDockerPort("9090")) {
+		t.Fatalf("Expected 9090/tcp to be reserved on all IPs")
+	}
+	if err := a.Reserve("127.0.0.1", MustParseDockerPort("9090/tcp")); err != nil {
+		t.Fatalf("Expected no error after release, got %v", err)
+	}
+}
+
+func TestPortAllocator_ReserveErrors(t *testing.T) {
+	var a PortAllocator
+	if err := a.Reserve("localhost", MustParseDockerPort("80")); !errors.Is(err, ErrInvalidPortBinding) {
+		t.Fatalf("Expected ErrInvalidPortBinding, got %v", err)
+	}
+	if err := a.Reserve("", DockerPort{Port: "0", Protocol: "tcp"}); !errors.Is(err, ErrInvalidPort) {
+		t.Fatalf("Expected ErrInvalidPort, got %v", err)
+	}
+	if _, err := a.Allocate("", "tcp"); !errors.Is(err, ErrNoFreePort) {
+		t.Fatalf("Expected ErrNoFreePort without ranges, got %v", err)
+	}
+	if _, err := NewPortAllocator(PortRange{10, 5}); !errors.Is(err, ErrInvalidPortRange) {
+		t.Fatalf("Expected ErrInvalidPortRange, got %v", err)
+	}
+}
+
+func TestPortAllocator_Allocate(t *testing.T) {
+	a, err := NewPortAllocator(PortRange{8000, 8002}, PortRange{9000, 9000})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := a.Reserve("", MustParseDockerPort("8001/tcp")); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, err := a.Allocate("", "icmp"); !errors.Is(err, ErrInvalidProtocol) {
+		t.Fatalf("Expected ErrInvalidProtocol, got %v", err)
+	}
+
+	var steps = []struct {
+		HostIP   string
+		Protocol string
+		Release  string
+		Expected string
+	}{
+		{"", "tcp", "", "8000/tcp"},
+		{"", "tcp", "", "8002/tcp"},
+		{"", "udp", "", "8000/udp"},
+		{"", "tcp", "", "9000/tcp"},
+		// Released ports are only reused after wrapping around.
+		{"", "tcp", "8000/tcp", "8000/tcp"},
+		{"", "tcp", "", ""},
+		{"", "tcp", "8002/tcp", "8002/tcp"},
+		{"127.0.0.1", "udp", "", "8001/udp"},
+		{"127.0.0.2", "udp", "", "8002/udp"},
+		{"", "udp", "", "9000/udp"},
+	}
+	for i, data := range steps {
+		if data.Release != "" && !a.Release("", MustParseDockerPort(data.Release)) {
+			t.Fatalf("Expected %s to be reserved in step %d", data.Release, i)
+		}
+		port, err := a.Allocate(data.HostIP, data.Protocol)
+		if data.Expected == "" {
+			if !errors.Is(err, ErrNoFreePort) {
+				t.Fatalf("Expected ErrNoFreePort in step %d, got %v, %v", i, port, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Expected no error in step %d, got %v", i, err)
+		}
+		if port.String() != data.Expected {
+			t.Fatalf("Expected %s in step %d, got %s", data.Expected, i, port.String())
+		}
+	}
+}
+
+func TestPortAllocator_AllocateConcurrently(t *testing.T) {
+	a, err := NewPortAllocator(PortRange{10000, 10999})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	ports := make(chan DockerPort, 1000)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				port, err := a.Allocate("", "tcp")
+				if err != nil {
+					t.Errorf("Expected no error, got %v", err)
+					return
+				}
+				ports <- port
+			}
+		}()
+	}
+	wg.Wait()
+	close(ports)
+
+	var set PortSet
+	for port := range ports {
+		if !set.Add(port) {
+			t.Fatalf("Expected %s to be allocated once", port.String())
+		}
+	}
+	if set.Len() != 1000 {
+		t.Fatalf("Expected 1000 ports, got %d", set.Len())
+	}
+	if _, err := a.Allocate("", "tcp"); !errors.Is(err, ErrNoFreePort) {
+		t.Fatalf("Expected ErrNoFreePort, got %v", err)
+	}
+}
+
+func TestPortAllocator_JSON(t *testing.T) {
+	a, err := NewPortAllocator(PortRange{8000, 8001})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	for _, hostIP := range []string{"::1", "127.0.0.1"} {
+		if err := a.Reserve(hostIP, MustParseDockerPort("53/udp")); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+	if _, err := a.Allocate("", "tcp"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	expected := `{"Ranges":["8000-8001"],"Reservations":[{"HostIp":"127.0.0.1","Port":"53/udp"},{"HostIp":"::1","Port":"53/udp"},{"HostIp":"","Port":"8000/tcp"}],"Next":{"tcp":8001}}`
+	data, err := json.Marshal(a)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if string(data) != expected {
+		t.Fatalf("Expected %s, got %s", expected, data)
+	}
+
+	var restored PortAllocator
+	if err := json.Unmarshal(data, &restored); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !reflect.DeepEqual(restored.Reservations(), a.Reservations()) {
+		t.Fatalf("Expected %v, got %v", a.Reservations(), restored.Reservations())
+	}
+	if port, err := restored.Allocate("", "tcp"); err != nil || port.String() != "8001/tcp" {
+		t.Fatalf("Expected 8001/tcp, got %v, %v", port, err)
+	}
+
+	empty, err := json.Marshal(&PortAllocator{})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if string(empty) != `{"Ranges":[],"Reservations":[]}` {
+		t.Fatalf("Expected empty snapshot, got %s", empty)
+	}
+}
+
+func TestPortAllocator_JSONKeepsCursor(t *testing.T) {
+	a, err := NewPortAllocator(PortRange{30000, 30010})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	port, err := a.Allocate("", "tcp")
+	if err != nil || port.String() != "30000/tcp" {
+		t.Fatalf("Expected 30000/tcp, got %v, %v", port, err)
+	}
+	if !a.Release("", port) {
+		t.Fatalf("Expected %v to be released", port)
+	}
+
+	data, err := json.Marshal(a)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	var restored PortAllocator
+	if err := json.Unmarshal(data, &restored); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if port, err := restored.Allocate("", "tcp"); err != nil || port.String() != "30001/tcp" {
+		t.Fatalf("Expected 30001/tcp after restoring %s, got %v, %v", data, port, err)
+	}
+	if port, err := restored.Allocate("", "udp"); err != nil || port.String() != "30000/udp" {
+		t.Fatalf("Expected 30000/udp, got %v, %v", port, err)
+	}
+}
+
+func TestPortAllocator_UnmarshalJSONErrors(t *testing.T) {
+	var snapshots = []string{
+		`{"Ranges":["80-70"],"Reservations":[]}`,
+		`{"Ranges":[],"Reservations":[{"HostIp":"","Port":"80/icmp"}]}`,
+		`{"Ranges":[],"Reservations":[{"HostIp":"a","Port":"80/tcp"}]}`,
+		`{"Ranges":[],"Reservations":[{"HostIp":"","Port":"80/tcp"},{"HostIp":"::1","Port":"80"}]}`,
+		`[]`,
+	}
+	for _, snapshot := range snapshots {
+		a, _ := NewPortAllocator(PortRange{1, 2})
+		if err := a.Reserve("", MustParseDockerPort("443")); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if err := json.Unmarshal([]byte(snapshot), a); err == nil {
+			t.Fatalf("Expected error for %s", snapshot)
+		}
+		if len(a.Reservations()) != 1 {
+			t.Fatalf("Expected allocator to be unchanged for %s, got %v", snapshot, a.Reservations())
+		}
+	}
+}
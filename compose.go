@@ -0,0 +1,305 @@
+package generictypes
+
+import (
+	"github.com/juju/errgo"
+	"gopkg.in/yaml.v3"
+
+	"io"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrInvalidComposeFile = errgo.New("Invalid compose file")
+)
+
+// ComposeFile is a docker-compose.yml whose images can be rewritten while
+// preserving comments, key order and formatting of the original file.
+type ComposeFile struct {
+	Services []ComposeService
+
+	source []byte
+	root   *yaml.Node
+}
+
+// ComposeService is a single service of a ComposeFile.
+type ComposeService struct {
+	Name string
+
+	// Image is the image of the service. It is empty for services that are
+	// only built, or if the image cannot be parsed.
+	Image         DockerImage
+	ImageLocation ImageLocation
+
+	// Ports are the published ports of the service.
+	Ports []ComposePortMapping
+
+	// Expose are the ports exposed to linked services only.
+	Expose []DockerPort
+
+	// UnresolvedPorts are the entries of ports and expose that use variables
+	// like ${PORT}, which cannot be parsed without the environment.
+	UnresolvedPorts []UnresolvedComposePort
+}
+
+// UnresolvedComposePort is a port entry using a variable.
+type UnresolvedComposePort struct {
+	// Input is the value containing the variable, e.g. "${PORT:-8080}:80".
+	Input string
+
+	// Line is the line of the entry in the compose file.
+	Line int
+}
+
+// ComposePortMapping is an entry of the ports section of a service.
+type ComposePortMapping struct {
+	// HostIP is the IP the port is published on, empty for all IPs.
+	HostIP string
+
+	// HostPort is the published port, a range like "8000-8010" or empty for
+	// a random port.
+	HostPort string
+
+	ContainerPort DockerPort
+
+	// Line is the line of the entry in the compose file.
+	Line int
+}
+
+// LoadComposeFile reads a compose file and returns its services. Images that
+// cannot be parsed, e.g. because they use variables, are returned as errors
+// instead of aborting. Ports using variables are recorded in UnresolvedPorts,
+// other invalid ports fail loading.
+func LoadComposeFile(r io.Reader) (*ComposeFile, []LocatedImageError, error) {
+	source, err := io.ReadAll(r)
+	if err != nil {
+		return nil, nil, maskAny(err)
+	}
+
+	f := &ComposeFile{}
+	imageErrors, err := f.load(source)
+	if err != nil {
+		return nil, nil, err
+	}
+	return f, imageErrors, nil
+}
+
+// Bytes returns the compose file including all rewritten images.
+func (f *ComposeFile) Bytes() []byte {
+	return append([]byte{}, f.source...)
+}
+
+// Service returns the service with the given name.
+func (f *ComposeFile) Service(name string) (ComposeService, bool) {
+	for _, service := range f.Services {
+		if service.Name == name {
+			return service, true
+		}
+	}
+	return ComposeService{}, false
+}
+
+// SetImage replaces the image of the given service in the compose file. Only
+// the image value is changed, keeping its quoting style. If the service has
+// no image yet, an image key is inserted as its first key.
+func (f *ComposeFile) SetImage(serviceName string, img DockerImage) error {
+	if err := img.Validate(); err != nil {
+		return err
+	}
+
+	service := yamlMappingValue(yamlMappingValue(f.root, "services"), serviceName)
+	if service == nil || service.Kind != yaml.MappingNode {
+		return notef(ErrInvalidComposeFile, "Unknown service %#v", serviceName)
+	}
+
+	var edit yamlEdit
+	if imageNode := yamlMappingValue(service, "image"); imageNode != nil {
+		var err error
+		if edit, err = yamlReplaceScalar(f.source, imageNode, img.String()); err != nil {
+			return err
+		}
+	} else {
+		var err error
+		if edit, err = yamlInsertKey(f.source, service, "image", img.String()); err != nil {
+			return notef(err, "Cannot add image to service %#v", serviceName)
+		}
+	}
+	source := applyYAMLEdits(f.source, []yamlEdit{edit})
+
+	// Reload, so that node positions and services match the new source.
+	updated := &ComposeFile{}
+	if _, err := updated.load(source); err != nil {
+		return err
+	}
+	*f = *updated
+	return nil
+}
+
+func (f *ComposeFile) load(source []byte) ([]LocatedImageError, error) {
+	var document yaml.Node
+	if err := yaml.Unmarshal(source, &document); err != nil {
+		return nil, notef(ErrInvalidComposeFile, "%v", err)
+	}
+
+	f.source = source
+	f.root = nil
+	f.Services = nil
+	if len(document.Content) == 0 {
+		return nil, nil
+	}
+	f.root = document.Content[0]
+
+	var imageErrors []LocatedImageError
+
+	services := yamlMappingValue(f.root, "services")
+	if services == nil {
+		return nil, nil
+	}
+	if services.Kind != yaml.MappingNode {
+		return nil, notef(ErrInvalidComposeFile, "Line %d: services must be a mapping", services.Line)
+	}
+
+	for i := 0; i+1 < len(services.Content); i += 2 {
+		name, node := services.Content[i].Value, services.Content[i+1]
+		service := ComposeService{Name: name}
+
+		if imageNode := yamlMappingValue(node, "image"); imageNode != nil {
+			service.ImageLocation = ImageLocation{
+				Kind:   "service",
+				Name:   name,
+				Path:   "services." + name + ".image",
+				Line:   imageNode.Line,
+				Column: imageNode.Column,
+			}
+			image, err := parseYAMLImage(imageNode)
+			if err != nil {
+				imageErrors = append(imageErrors, LocatedImageError{Input: imageNode.Value, Location: service.ImageLocation, Err: err})
+			} else {
+				service.Image = image
+			}
+		}
+
+		if ports := yamlMappingValue(node, "ports"); ports != nil {
+			for _, portNode := range ports.Content {
+				if input, ok := composeVariable(portNode); ok {
+					service.UnresolvedPorts = append(service.UnresolvedPorts, UnresolvedComposePort{Input: input, Line: portNode.Line})
+					continue
+				}
+				mappings, err := parseComposePort(portNode)
+				if err != nil {
+					return nil, notef(err, "Line %d: Invalid port in service %#v", portNode.Line, name)
+				}
+				service.Ports = append(service.Ports, mappings...)
+			}
+		}
+
+		if expose := yamlMappingValue(node, "expose"); expose != nil {
+			for _, portNode := range expose.Content {
+				if input, ok := composeVariable(portNode); ok {
+					service.UnresolvedPorts = append(service.UnresolvedPorts, UnresolvedComposePort{Input: input, Line: portNode.Line})
+					continue
+				}
+				ports, err := parseComposePortRange(portNode.Value)
+				if err != nil {
+					return nil, notef(err, "Line %d: Invalid exposed port in service %#v", portNode.Line, name)
+				}
+				service.Expose = append(service.Expose, ports...)
+			}
+		}
+
+		f.Services = append(f.Services, service)
+	}
+
+	return imageErrors, nil
+}
+
+// parseComposePort parses an entry of the ports section in short syntax like
+// "127.0.0.1:8080:80/udp" or long syntax with target, published, host_ip and
+// protocol keys. Port ranges result in one mapping per port.
+func parseComposePort(node *yaml.Node) ([]ComposePortMapping, error) {
+	var specs []portSpec
+
+	switch node.Kind {
+	case yaml.ScalarNode:
+		var err error
+		if specs, err = parsePortSpec(node.Value); err != nil {
+			return nil, err
+		}
+	case yaml.MappingNode:
+		containerPort := yamlScalarValue(yamlMappingValue(node, "target"))
+		if protocol := yamlScalarValue(yamlMappingValue(node, "protocol")); protocol != "" {
+			containerPort += "/" + protocol
+		}
+		var err error
+		specs, err = expandPortSpec(
+			yamlScalarValue(yamlMappingValue(node, "host_ip")),
+			yamlScalarValue(yamlMappingValue(node, "published")),
+			containerPort,
+		)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, newPortError(node.Value, ErrInvalidPortFormat, "Port must be a string, number or mapping")
+	}
+
+	mappings := make([]ComposePortMapping, 0, len(specs))
+	for _, spec := range specs {
+		mappings = append(mappings, ComposePortMapping{HostIP: spec.HostIP, HostPort: spec.HostPort, ContainerPort: spec.ContainerPort, Line: node.Line})
+	}
+	return mappings, nil
+}
+
+// composeVariable returns the first scalar of the node that uses a variable,
+// e.g. "${PORT}:80" or the published value of a long syntax port.
+func composeVariable(node *yaml.Node) (string, bool) {
+	if node.Kind == yaml.ScalarNode {
+		return node.Value, strings.Contains(node.Value, "$")
+	}
+	for _, child := range node.Content {
+		if value, ok := composeVariable(child); ok {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+// parseComposePortRange parses "<port>[/<protocol>]" where port may be a
+// range like "8000-8010".
+func parseComposePortRange(input string) ([]DockerPort, error) {
+	protocol := ""
+	if i := strings.Index(input, "/"); i >= 0 {
+		input, protocol = input[:i], input[i:]
+	}
+
+	bounds := strings.Split(input, "-")
+	if len(bounds) == 1 {
+		port, err := ParseDockerPort(input + protocol)
+		if err != nil {
+			return nil, err
+		}
+		return []DockerPort{port}, nil
+	}
+	if len(bounds) != 2 {
+		return nil, newPortError(input, ErrInvalidPortRange, "Invalid port range %#v", input)
+	}
+
+	first, err := ParseDockerPort(bounds[0] + protocol)
+	if err != nil {
+		return nil, err
+	}
+	last, err := ParseDockerPort(bounds[1] + protocol)
+	if err != nil {
+		return nil, err
+	}
+	from, to := int(first.Number()), int(last.Number())
+	if from > to {
+		return nil, newPortError(input, ErrInvalidPortRange, "Invalid port range %#v", input)
+	}
+
+	ports := make([]DockerPort, 0, to-from+1)
+	for port := from; port <= to; port++ {
+		ports = append(ports, MustParseDockerPort(strconv.Itoa(port)+protocol))
+	}
+	return ports, nil
+}
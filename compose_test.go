@@ -0,0 +1,254 @@
+package generictypes
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testComposeFile = `# Local development setup.
+version: "3.9"
+
+services:
+  # The API server.
+  api:
+    image: quay.io/giantswarm/api:1.0.0   # bumped by CI
+    ports:
+      - "8080:80"
+      - 127.0.0.1:9090:9090/udp
+      - "[::1]:6000-6001:7000-7001"
+      - 443
+      - target: 53
+        published: "5353"
+        host_ip: 0.0.0.0
+        protocol: udp
+        mode: host
+    expose:
+      - "3000"
+      - 4000-4001/udp
+
+  db:
+    image: 'postgres:16'
+    environment:
+      POSTGRES_PASSWORD: secret
+
+  cache:
+    image: "redis:7"
+
+  worker:
+    build: ./worker
+    command: ["run"]
+
+  templated:
+    image: ${REGISTRY}/app:${TAG:-latest}
+    ports:
+      - "${PORT:-8080}:80"
+      - target: 443
+        published: ${HTTPS_PORT}
+      - 9000
+    expose:
+      - $METRICS_PORT
+`
+
+func TestLoadComposeFile(t *testing.T) {
+	f, imageErrors, err := LoadComposeFile(strings.NewReader(testComposeFile))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if len(imageErrors) != 1 || imageErrors[0].Location.Name != "templated" || imageErrors[0].Location.Line != 35 {
+		t.Fatalf("Unexpected image errors %#v", imageErrors)
+	}
+
+	var serviceImages = []struct {
+		Service string
+		Image   string
+	}{
+		{"api", "quay.io/giantswarm/api:1.0.0"},
+		{"db", "postgres:16"},
+		{"cache", "redis:7"},
+		{"worker", ""},
+		{"templated", ""},
+	}
+	if len(f.Services) != len(serviceImages) {
+		t.Fatalf("Expected %d services, got %#v", len(serviceImages), f.Services)
+	}
+	for i, data := range serviceImages {
+		if f.Services[i].Name != data.Service || f.Services[i].Image.String() != data.Image {
+			t.Fatalf("Expected service %s with image '%s', got %#v", data.Service, data.Image, f.Services[i])
+		}
+	}
+
+	api, _ := f.Service("api")
+	if api.ImageLocation.Line != 7 {
+		t.Fatalf("Expected image on line 7, got %d", api.ImageLocation.Line)
+	}
+
+	var expectedPorts = []string{
+		"|8080|80/tcp",
+		"127.0.0.1|9090|9090/udp",
+		"::1|6000|7000/tcp",
+		"::1|6001|7001/tcp",
+		"||443/tcp",
+		"0.0.0.0|5353|53/udp",
+	}
+	var ports []string
+	for _, port := range api.Ports {
+		ports = append(ports, port.HostIP+"|"+port.HostPort+"|"+port.ContainerPort.String())
+	}
+	if strings.Join(ports, ",") != strings.Join(expectedPorts, ",") {
+		t.Fatalf("Expected ports %v, got %v", expectedPorts, ports)
+	}
+
+	var expose []string
+	for _, port := range api.Expose {
+		expose = append(expose, port.String())
+	}
+	if strings.Join(expose, ",") != "3000/tcp,4000/udp,4001/udp" {
+		t.Fatalf("Unexpected exposed ports %v", expose)
+	}
+	if api.UnresolvedPorts != nil {
+		t.Fatalf("Expected no unresolved ports, got %v", api.UnresolvedPorts)
+	}
+
+	templated, _ := f.Service("templated")
+	var expectedUnresolved = []UnresolvedComposePort{
+		{"${PORT:-8080}:80", 37},
+		{"${HTTPS_PORT}", 38},
+		{"$METRICS_PORT", 42},
+	}
+	if !reflect.DeepEqual(templated.UnresolvedPorts, expectedUnresolved) {
+		t.Fatalf("Expected unresolved ports %v, got %v", expectedUnresolved, templated.UnresolvedPorts)
+	}
+	if len(templated.Ports) != 1 || templated.Ports[0].ContainerPort.String() != "9000/tcp" {
+		t.Fatalf("Expected port 9000 of templated service, got %v", templated.Ports)
+	}
+}
+
+func TestComposeFile_SetImageNonASCII(t *testing.T) {
+	source := "services:\n  café: {image: \"redis:6\", command: \"ünïcode\"}\n"
+	f, _, err := LoadComposeFile(strings.NewReader(source))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := f.SetImage("café", MustParseDockerImage("redis:7")); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	expected := strings.Replace(source, "redis:6", "redis:7", 1)
+	if string(f.Bytes()) != expected {
+		t.Fatalf("Expected %s, got %s", expected, f.Bytes())
+	}
+}
+
+var composeSetImageShapes = []struct {
+	Name     string
+	Source   string
+	Expected string
+}{
+	{
+		"flow mapping",
+		"services:\n  web: {image: nginx:1.0, ports: [\"80\"]}\n",
+		"services:\n  web: {image: nginx:1.1, ports: [\"80\"]}\n",
+	},
+	{
+		"flow mapping end",
+		"services:\n  web: {ports: [\"80\"], image: nginx:1.0}\n",
+		"services:\n  web: {ports: [\"80\"], image: nginx:1.1}\n",
+	},
+	{
+		"anchor",
+		"services:\n  web:\n    image: &img nginx:1.0 # pinned\n  proxy:\n    image: *img\n",
+		"services:\n  web:\n    image: &img nginx:1.1 # pinned\n  proxy:\n    image: *img\n",
+	},
+	{
+		"tag and anchor",
+		"services:\n  web:\n    image: !!str &img 'nginx:1.0'\n",
+		"services:\n  web:\n    image: !!str &img 'nginx:1.1'\n",
+	},
+}
+
+func TestComposeFile_SetImageShapes(t *testing.T) {
+	for _, data := range composeSetImageShapes {
+		f, _, err := LoadComposeFile(strings.NewReader(data.Source))
+		if err != nil {
+			t.Fatalf("%s: Expected no error, got %v", data.Name, err)
+		}
+		if err := f.SetImage("web", MustParseDockerImage("nginx:1.1")); err != nil {
+			t.Fatalf("%s: Expected no error, got %v", data.Name, err)
+		}
+		if string(f.Bytes()) != data.Expected {
+			t.Fatalf("%s: Expected\n%s\ngot\n%s", data.Name, data.Expected, f.Bytes())
+		}
+		if web, _ := f.Service("web"); web.Image.String() != "nginx:1.1" {
+			t.Fatalf("%s: Expected nginx:1.1, got %#v", data.Name, web)
+		}
+	}
+}
+
+var invalidComposePorts = []struct {
+	Input string
+}{
+	{`["80:80:80:80"]`},
+	{`["abc"]`},
+	{`["8080:80/icmp"]`},
+	{`["300.0.0.1:80:80"]`},
+	{`["90-80"]`},
+	{`[{published: 8080}]`},
+	{`[[80]]`},
+}
+
+func TestLoadComposeFileInvalidPorts(t *testing.T) {
+	for _, data := range invalidComposePorts {
+		_, _, err := LoadComposeFile(strings.NewReader("services:\n  app:\n    image: redis\n    ports: " + data.Input + "\n"))
+		if err == nil {
+			t.Fatalf("Expected error for ports %s", data.Input)
+		}
+	}
+}
+
+func TestComposeFile_SetImage(t *testing.T) {
+	f, _, err := LoadComposeFile(strings.NewReader(testComposeFile))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	var changes = []struct {
+		Service string
+		Image   string
+	}{
+		{"api", "quay.io/giantswarm/api:1.1.0"},
+		{"db", "postgres:16.1"},
+		{"cache", "redis:7.2"},
+		{"worker", "quay.io/giantswarm/worker:1.0.0"},
+		{"templated", "quay.io/giantswarm/app:2.0.0"},
+	}
+	for _, data := range changes {
+		if err := f.SetImage(data.Service, MustParseDockerImage(data.Image)); err != nil {
+			t.Fatalf("Failed to set image of %s: %v", data.Service, err)
+		}
+	}
+
+	expected := strings.NewReplacer(
+		"api:1.0.0   # bumped by CI", "api:1.1.0   # bumped by CI",
+		"'postgres:16'", "'postgres:16.1'",
+		`"redis:7"`, `"redis:7.2"`,
+		"    build: ./worker", "    image: quay.io/giantswarm/worker:1.0.0\n    build: ./worker",
+		"${REGISTRY}/app:${TAG:-latest}", "quay.io/giantswarm/app:2.0.0",
+	).Replace(testComposeFile)
+
+	if string(f.Bytes()) != expected {
+		t.Fatalf("Expected compose file\n%s\ngot\n%s", expected, string(f.Bytes()))
+	}
+
+	worker, _ := f.Service("worker")
+	if worker.Image.String() != "quay.io/giantswarm/worker:1.0.0" {
+		t.Fatalf("Expected services to be updated, got %#v", worker)
+	}
+
+	if err := f.SetImage("unknown", MustParseDockerImage("redis")); err == nil {
+		t.Fatalf("Expected error for unknown service")
+	}
+	if err := f.SetImage("api", DockerImage{Repository: "Invalid"}); err == nil {
+		t.Fatalf("Expected error for invalid image")
+	}
+}
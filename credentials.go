@@ -0,0 +1,211 @@
+package generictypes
+
+import (
+	"github.com/juju/errgo"
+
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// DockerHubCredentialsKey is the key Docker uses for Docker Hub in its
+// config file and when talking to credential helpers.
+const DockerHubCredentialsKey = "https://index.docker.io/v1/"
+
+var (
+	ErrInvalidDockerConfig = errgo.New("Invalid docker config")
+	ErrCredentialHelper    = errgo.New("Credential helper failed")
+)
+
+// DockerConfig is the part of ~/.docker/config.json that deals with
+// registry credentials.
+type DockerConfig struct {
+	Auths       map[string]DockerAuthConfig `json:"auths,omitempty"`
+	CredsStore  string                      `json:"credsStore,omitempty"`
+	CredHelpers map[string]string           `json:"credHelpers,omitempty"`
+}
+
+// DockerAuthConfig is a single entry of the auths section of a DockerConfig.
+type DockerAuthConfig struct {
+	// Auth is the base64 encoded "<username>:<password>".
+	Auth string `json:"auth,omitempty"`
+
+	Username      string `json:"username,omitempty"`
+	Password      string `json:"password,omitempty"`
+	IdentityToken string `json:"identitytoken,omitempty"`
+}
+
+// DockerCredentials are the credentials to use for a registry.
+type DockerCredentials struct {
+	Username string
+	Password string
+
+	// IdentityToken is an OAuth2 refresh token used instead of username and
+	// password to obtain registry tokens.
+	IdentityToken string
+}
+
+// Empty returns true if no credentials are set, false otherwise.
+func (c DockerCredentials) Empty() bool {
+	return c.Username == "" && c.Password == "" && c.IdentityToken == ""
+}
+
+// LoadDockerConfig reads the docker config file at the given path.
+func LoadDockerConfig(path string) (DockerConfig, error) {
+	var config DockerConfig
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return config, maskAny(err)
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return config, notef(ErrInvalidDockerConfig, "%s: %v", path, err)
+	}
+	return config, nil
+}
+
+// DefaultDockerConfigPath returns the path of the docker config file, honoring
+// the DOCKER_CONFIG environment variable like the docker CLI does.
+func DefaultDockerConfigPath() (string, error) {
+	if dir := os.Getenv("DOCKER_CONFIG"); dir != "" {
+		return filepath.Join(dir, "config.json"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", maskAny(err)
+	}
+	return filepath.Join(home, ".docker", "config.json"), nil
+}
+
+// CredentialResolver looks up registry credentials for images the same way
+// the docker CLI does: credHelpers first, then credsStore, then auths.
+type CredentialResolver struct {
+	Config DockerConfig
+}
+
+// NewCredentialResolver returns a resolver for the default docker config
+// file. A missing config file results in a resolver without credentials.
+func NewCredentialResolver() (*CredentialResolver, error) {
+	path, err := DefaultDockerConfigPath()
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	config, err := LoadDockerConfig(path)
+	if os.IsNotExist(errgo.Cause(err)) {
+		return &CredentialResolver{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return &CredentialResolver{Config: config}, nil
+}
+
+// Resolve returns the credentials for the registry of the given image.
+// Empty credentials are returned if none are configured.
+func (r *CredentialResolver) Resolve(img DockerImage) (DockerCredentials, error) {
+	key := credentialsKey(img)
+
+	for helperKey, helper := range r.Config.CredHelpers {
+		if normalizeCredentialsKey(helperKey) == key {
+			return r.fromHelper(helper, key)
+		}
+	}
+	if r.Config.CredsStore != "" {
+		creds, err := r.fromHelper(r.Config.CredsStore, key)
+		if err != nil || !creds.Empty() {
+			return creds, err
+		}
+	}
+	return r.fromAuths(key)
+}
+
+func (r *CredentialResolver) fromAuths(key string) (DockerCredentials, error) {
+	for authKey, auth := range r.Config.Auths {
+		if normalizeCredentialsKey(authKey) != key {
+			continue
+		}
+
+		creds := DockerCredentials{
+			Username:      auth.Username,
+			Password:      auth.Password,
+			IdentityToken: auth.IdentityToken,
+		}
+		if auth.Auth != "" {
+			decoded, err := base64.StdEncoding.DecodeString(auth.Auth)
+			if err != nil {
+				return DockerCredentials{}, notef(ErrInvalidDockerConfig, "Invalid auth for %s", authKey)
+			}
+			parts := strings.SplitN(string(decoded), ":", 2)
+			if len(parts) != 2 {
+				return DockerCredentials{}, notef(ErrInvalidDockerConfig, "Invalid auth for %s", authKey)
+			}
+			creds.Username = parts[0]
+			creds.Password = parts[1]
+		}
+		return creds, nil
+	}
+	return DockerCredentials{}, nil
+}
+
+// fromHelper runs `docker-credential-<helper> get` with the server URL on
+// stdin, see https://github.com/docker/docker-credential-helpers.
+func (r *CredentialResolver) fromHelper(helper, key string) (DockerCredentials, error) {
+	var stdout, stderr bytes.Buffer
+
+	cmd := exec.Command("docker-credential-"+helper, "get")
+	cmd.Stdin = strings.NewReader(key)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		output := strings.TrimSpace(stdout.String())
+		if output == "credentials not found in native keychain" {
+			return DockerCredentials{}, nil
+		}
+		if output == "" {
+			output = strings.TrimSpace(stderr.String())
+		}
+		return DockerCredentials{}, notef(ErrCredentialHelper, "%s: %v: %s", helper, err, output)
+	}
+
+	var response struct {
+		ServerURL string
+		Username  string
+		Secret    string
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &response); err != nil {
+		return DockerCredentials{}, notef(ErrCredentialHelper, "%s: Invalid output: %v", helper, err)
+	}
+
+	// Helpers signal identity tokens with this magic username.
+	if response.Username == "<token>" {
+		return DockerCredentials{IdentityToken: response.Secret}, nil
+	}
+	return DockerCredentials{Username: response.Username, Password: response.Secret}, nil
+}
+
+// credentialsKey returns the key under which docker stores the credentials
+// for the registry of the given image.
+func credentialsKey(img DockerImage) string {
+	return normalizeCredentialsKey(img.Registry)
+}
+
+// normalizeCredentialsKey strips scheme and path from config keys like
+// "https://quay.io/v1/" and maps all Docker Hub aliases to the key the docker
+// CLI uses for it.
+func normalizeCredentialsKey(key string) string {
+	host := strings.TrimPrefix(strings.TrimPrefix(key, "https://"), "http://")
+	if i := strings.IndexByte(host, '/'); i >= 0 {
+		host = host[:i]
+	}
+
+	switch host {
+	case "", "docker.io", "index.docker.io", DockerHubRegistry:
+		return DockerHubCredentialsKey
+	default:
+		return host
+	}
+}
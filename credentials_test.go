@@ -0,0 +1,196 @@
+package generictypes
+
+import (
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/juju/errgo"
+)
+
+// writeCredentialHelper installs a fake docker-credential-<name> binary in a
+// temporary directory on the PATH, which prints the given output and exits
+// with the given code.
+func writeCredentialHelper(t *testing.T, name, output string, exitCode int) {
+	dir := t.TempDir()
+	script := "#!/bin/sh\ncat > " + filepath.Join(dir, "stdin") + "\nprintf '%s' '" + output + "'\nexit " + strconv.Itoa(exitCode) + "\n"
+	if err := os.WriteFile(filepath.Join(dir, "docker-credential-"+name), []byte(script), 0755); err != nil {
+		t.Fatalf("Failed to write credential helper: %v", err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func testBasicAuth(username, password string) string {
+	return base64.StdEncoding.EncodeToString([]byte(username + ":" + password))
+}
+
+func TestCredentialResolver_Auths(t *testing.T) {
+	resolver := &CredentialResolver{Config: DockerConfig{
+		Auths: map[string]DockerAuthConfig{
+			DockerHubCredentialsKey:   {Auth: testBasicAuth("hub", "hub-secret")},
+			"https://quay.io/v1/":     {Username: "quay", Password: "quay-secret"},
+			"registry.giantswarm.io":  {IdentityToken: "refresh-token"},
+			"192.168.59.103:5000":     {Auth: testBasicAuth("local", "pass:with:colons")},
+			"registry.unrelated.test": {Auth: testBasicAuth("other", "other")},
+		},
+	}}
+
+	var resolvedCredentials = []struct {
+		Image    string
+		Expected DockerCredentials
+	}{
+		{"redis", DockerCredentials{Username: "hub", Password: "hub-secret"}},
+		{"docker.io/giantswarm/app", DockerCredentials{Username: "hub", Password: "hub-secret"}},
+		{"index.docker.io/giantswarm/app", DockerCredentials{Username: "hub", Password: "hub-secret"}},
+		{"quay.io/giantswarm/app", DockerCredentials{Username: "quay", Password: "quay-secret"}},
+		{"registry.giantswarm.io/app", DockerCredentials{IdentityToken: "refresh-token"}},
+		{"192.168.59.103:5000/sharethemeal/payment", DockerCredentials{Username: "local", Password: "pass:with:colons"}},
+		{"gcr.io/app", DockerCredentials{}},
+	}
+
+	for _, data := range resolvedCredentials {
+		creds, err := resolver.Resolve(MustParseDockerImage(data.Image))
+		if err != nil {
+			t.Fatalf("Expected no error for %s, got %v", data.Image, err)
+		}
+		if creds != data.Expected {
+			t.Fatalf("Expected credentials %#v for %s, got %#v", data.Expected, data.Image, creds)
+		}
+	}
+}
+
+func TestCredentialResolver_InvalidAuth(t *testing.T) {
+	resolver := &CredentialResolver{Config: DockerConfig{
+		Auths: map[string]DockerAuthConfig{
+			"quay.io": {Auth: "not base64!"},
+		},
+	}}
+
+	if _, err := resolver.Resolve(MustParseDockerImage("quay.io/giantswarm/app")); !errors.Is(err, ErrInvalidDockerConfig) {
+		t.Fatalf("Expected ErrInvalidDockerConfig for invalid auth, got %v", err)
+	}
+}
+
+func TestCredentialResolver_CredHelpers(t *testing.T) {
+	writeCredentialHelper(t, "fake", `{"ServerURL":"gcr.io","Username":"_json_key","Secret":"helper-secret"}`, 0)
+	writeCredentialHelper(t, "token", `{"ServerURL":"quay.io","Username":"<token>","Secret":"identity"}`, 0)
+
+	resolver := &CredentialResolver{Config: DockerConfig{
+		Auths: map[string]DockerAuthConfig{
+			"gcr.io": {Username: "ignored", Password: "ignored"},
+		},
+		CredHelpers: map[string]string{
+			"gcr.io":  "fake",
+			"quay.io": "token",
+		},
+	}}
+
+	creds, err := resolver.Resolve(MustParseDockerImage("gcr.io/giantswarm/app"))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if creds != (DockerCredentials{Username: "_json_key", Password: "helper-secret"}) {
+		t.Fatalf("Unexpected credentials %#v", creds)
+	}
+
+	creds, err = resolver.Resolve(MustParseDockerImage("quay.io/giantswarm/app"))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if creds != (DockerCredentials{IdentityToken: "identity"}) {
+		t.Fatalf("Unexpected credentials %#v", creds)
+	}
+}
+
+func TestCredentialResolver_CredsStore(t *testing.T) {
+	writeCredentialHelper(t, "store", `{"ServerURL":"https://index.docker.io/v1/","Username":"hub","Secret":"store-secret"}`, 0)
+
+	resolver := &CredentialResolver{Config: DockerConfig{CredsStore: "store"}}
+
+	creds, err := resolver.Resolve(MustParseDockerImage("redis"))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if creds != (DockerCredentials{Username: "hub", Password: "store-secret"}) {
+		t.Fatalf("Unexpected credentials %#v", creds)
+	}
+
+	data, err := os.ReadFile(filepath.Join(filepath.SplitList(os.Getenv("PATH"))[0], "stdin"))
+	if err != nil {
+		t.Fatalf("Failed to read helper input: %v", err)
+	}
+	if string(data) != DockerHubCredentialsKey {
+		t.Fatalf("Expected helper to be asked for '%s', got '%s'", DockerHubCredentialsKey, string(data))
+	}
+}
+
+func TestCredentialResolver_CredsStoreNotFound(t *testing.T) {
+	writeCredentialHelper(t, "store", "credentials not found in native keychain", 1)
+
+	resolver := &CredentialResolver{Config: DockerConfig{
+		CredsStore: "store",
+		Auths: map[string]DockerAuthConfig{
+			"quay.io": {Username: "quay", Password: "quay-secret"},
+		},
+	}}
+
+	creds, err := resolver.Resolve(MustParseDockerImage("quay.io/giantswarm/app"))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if creds != (DockerCredentials{Username: "quay", Password: "quay-secret"}) {
+		t.Fatalf("Unexpected credentials %#v", creds)
+	}
+}
+
+func TestCredentialResolver_HelperErrors(t *testing.T) {
+	writeCredentialHelper(t, "broken", "something went wrong", 1)
+	writeCredentialHelper(t, "garbage", "not json", 0)
+
+	var helpers = []string{"broken", "garbage", "missing"}
+
+	for _, helper := range helpers {
+		resolver := &CredentialResolver{Config: DockerConfig{CredsStore: helper}}
+
+		creds, err := resolver.Resolve(MustParseDockerImage("redis"))
+		if !errors.Is(err, ErrCredentialHelper) || errgo.Cause(err) != ErrCredentialHelper {
+			t.Fatalf("Expected ErrCredentialHelper for helper %s, got %#v, %v", helper, creds, err)
+		}
+	}
+}
+
+func TestLoadDockerConfig(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("DOCKER_CONFIG", dir)
+
+	resolver, err := NewCredentialResolver()
+	if err != nil {
+		t.Fatalf("Expected no error for missing config, got %v", err)
+	}
+	if len(resolver.Config.Auths) != 0 {
+		t.Fatalf("Expected no auths, got %v", resolver.Config.Auths)
+	}
+
+	config := `{"auths":{"quay.io":{"auth":"` + testBasicAuth("quay", "secret") + `"}},"credsStore":"desktop","credHelpers":{"gcr.io":"gcloud"}}`
+	if err := os.WriteFile(filepath.Join(dir, "config.json"), []byte(config), 0600); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	resolver, err = NewCredentialResolver()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if resolver.Config.CredsStore != "desktop" || resolver.Config.CredHelpers["gcr.io"] != "gcloud" || resolver.Config.Auths["quay.io"].Auth == "" {
+		t.Fatalf("Unexpected config %#v", resolver.Config)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "config.json"), []byte("{"), 0600); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	if _, err := NewCredentialResolver(); !errors.Is(err, ErrInvalidDockerConfig) {
+		t.Fatalf("Expected ErrInvalidDockerConfig for invalid config, got %v", err)
+	}
+}
@@ -0,0 +1,328 @@
+package generictypes
+
+import (
+	"github.com/juju/errgo"
+
+	"bufio"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// DockerfileImage is an image a Dockerfile builds from or copies from.
+type DockerfileImage struct {
+	Image DockerImage
+
+	// Location.Kind is the instruction, "FROM" or "COPY", Location.Name the
+	// name of the stage the instruction belongs to and Location.Line the line
+	// the instruction starts at.
+	Location ImageLocation
+
+	// Platform is the --platform flag of a FROM instruction after ARG
+	// substitution, e.g. "linux/arm64". It is empty if the flag is not set.
+	Platform string
+}
+
+var patternDockerfileDirective = regexp.MustCompile(`^#\s*([a-zA-Z][a-zA-Z0-9]*)\s*=\s*(\S+)\s*$`)
+
+// dockerfileInstruction is a single instruction with continuation lines joined.
+type dockerfileInstruction struct {
+	Command string // upper case, e.g. "FROM"
+	Args    string
+	Line    int
+}
+
+// ParseDockerfileImages reads a Dockerfile and returns the images of all FROM
+// instructions and COPY --from flags. ARG defaults declared before the first
+// FROM and the given build args are substituted. References to earlier build
+// stages and "scratch" are not images and thus skipped. Images that cannot be
+// parsed are returned as errors instead of aborting.
+func ParseDockerfileImages(r io.Reader, buildArgs map[string]string) ([]DockerfileImage, []LocatedImageError, error) {
+	instructions, err := readDockerfileInstructions(r)
+	if err != nil {
+		return nil, nil, maskAny(err)
+	}
+
+	var (
+		images      []DockerfileImage
+		imageErrors []LocatedImageError
+
+		// Only ARGs declared before the first FROM can be used in FROM lines.
+		globalArgs = automaticDockerfileArgs(buildArgs)
+		stages     = map[string]bool{}
+		stage      string
+		stageCount int
+		seenFrom   bool
+	)
+
+	addImage := func(input string, location ImageLocation, platform string) {
+		image, err := ParseDockerImage(input)
+		if err != nil {
+			imageErrors = append(imageErrors, LocatedImageError{Input: input, Location: location, Err: err})
+			return
+		}
+		images = append(images, DockerfileImage{Image: image, Location: location, Platform: platform})
+	}
+
+	for _, instruction := range instructions {
+		switch instruction.Command {
+		case "ARG":
+			if seenFrom {
+				continue
+			}
+			for _, arg := range splitDockerfileArgs(instruction.Args) {
+				parts := strings.SplitN(arg, "=", 2)
+				name := parts[0]
+				if value, ok := buildArgs[name]; ok {
+					globalArgs[name] = value
+				} else if len(parts) == 2 {
+					globalArgs[name] = unquoteDockerfileWord(expandDockerfileArgs(parts[1], globalArgs))
+				} else {
+					globalArgs[name] = ""
+				}
+			}
+
+		case "FROM":
+			seenFrom = true
+
+			var platform string
+			args := splitDockerfileArgs(instruction.Args)
+			for len(args) > 0 && strings.HasPrefix(args[0], "--") {
+				if strings.HasPrefix(args[0], "--platform=") {
+					platform = expandDockerfileArgs(strings.TrimPrefix(args[0], "--platform="), globalArgs)
+				}
+				args = args[1:]
+			}
+			if len(args) == 0 {
+				return nil, nil, errgo.Newf("Line %d: FROM requires an image", instruction.Line)
+			}
+
+			input := expandDockerfileArgs(args[0], globalArgs)
+			stage = strconv.Itoa(stageCount)
+			if len(args) == 3 && strings.EqualFold(args[1], "AS") {
+				stage = strings.ToLower(args[2])
+			}
+
+			location := ImageLocation{Kind: "FROM", Name: stage, Line: instruction.Line}
+			if !stages[strings.ToLower(input)] && input != "scratch" {
+				addImage(input, location, platform)
+			}
+
+			stages[stage] = true
+			stages[strconv.Itoa(stageCount)] = true
+			stageCount++
+
+		case "COPY":
+			for _, arg := range splitDockerfileArgs(instruction.Args) {
+				if !strings.HasPrefix(arg, "--") {
+					break
+				}
+				if !strings.HasPrefix(arg, "--from=") {
+					continue
+				}
+
+				input := expandDockerfileArgs(strings.TrimPrefix(arg, "--from="), globalArgs)
+				if !stages[strings.ToLower(input)] {
+					addImage(input, ImageLocation{Kind: "COPY", Name: stage, Line: instruction.Line}, "")
+				}
+			}
+		}
+	}
+
+	return images, imageErrors, nil
+}
+
+// automaticDockerfileArgs returns the given build args that BuildKit
+// provides without an ARG declaration, like BUILDPLATFORM.
+func automaticDockerfileArgs(buildArgs map[string]string) map[string]string {
+	args := map[string]string{}
+	for _, name := range []string{"BUILDPLATFORM", "BUILDOS", "BUILDARCH", "BUILDVARIANT", "TARGETPLATFORM", "TARGETOS", "TARGETARCH", "TARGETVARIANT"} {
+		if value, ok := buildArgs[name]; ok {
+			args[name] = value
+		}
+	}
+	return args
+}
+
+// readDockerfileInstructions splits a Dockerfile into instructions, skipping
+// comments and joining continuation lines. The escape parser directive is
+// honored.
+func readDockerfileInstructions(r io.Reader) ([]dockerfileInstruction, error) {
+	var (
+		instructions []dockerfileInstruction
+		current      *dockerfileInstruction
+		escape       = `\`
+		directives   = true
+	)
+
+	scanner := bufio.NewScanner(r)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+
+		// Parser directives like "# escape=`" are only allowed at the top.
+		if directives {
+			if match := patternDockerfileDirective.FindStringSubmatch(line); match != nil {
+				if strings.ToLower(match[1]) == "escape" {
+					escape = match[2]
+					if escape != `\` && escape != "`" {
+						return nil, errgo.Newf("Line %d: Invalid escape directive %#v", lineNumber, escape)
+					}
+				}
+				continue
+			}
+			directives = false
+		}
+
+		// Comments and empty lines are skipped, also within continuations.
+		if strings.HasPrefix(line, "#") || line == "" {
+			continue
+		}
+
+		continued := strings.HasSuffix(line, escape)
+		if continued {
+			line = strings.TrimSuffix(line, escape)
+		}
+
+		if current == nil {
+			current = &dockerfileInstruction{Command: strings.ToUpper(line), Line: lineNumber}
+			if i := strings.IndexAny(line, " \t"); i >= 0 {
+				current.Command = strings.ToUpper(line[:i])
+				current.Args = strings.TrimSpace(line[i:])
+			}
+		} else {
+			current.Args = strings.TrimSpace(current.Args + " " + line)
+		}
+
+		if !continued {
+			instructions = append(instructions, *current)
+			current = nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, maskAny(err)
+	}
+	if current != nil {
+		instructions = append(instructions, *current)
+	}
+
+	return instructions, nil
+}
+
+// splitDockerfileArgs splits instruction arguments at whitespace outside of
+// quotes.
+func splitDockerfileArgs(args string) []string {
+	var (
+		result []string
+		word   strings.Builder
+		quote  rune
+	)
+
+	for _, c := range args {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+			word.WriteRune(c)
+		case c == '"' || c == '\'':
+			quote = c
+			word.WriteRune(c)
+		case c == ' ' || c == '\t':
+			if word.Len() > 0 {
+				result = append(result, word.String())
+				word.Reset()
+			}
+		default:
+			word.WriteRune(c)
+		}
+	}
+	if word.Len() > 0 {
+		result = append(result, word.String())
+	}
+
+	return result
+}
+
+// unquoteDockerfileWord removes surrounding single or double quotes.
+func unquoteDockerfileWord(word string) string {
+	if len(word) >= 2 && (word[0] == '"' || word[0] == '\'') && word[len(word)-1] == word[0] {
+		return word[1 : len(word)-1]
+	}
+	return word
+}
+
+// expandDockerfileArgs substitutes $NAME, ${NAME}, ${NAME:-default} and
+// ${NAME:+alternative} with the given args. Defaults and alternatives may be
+// nested, e.g. ${A:-${B}}. Unknown args expand to "".
+func expandDockerfileArgs(input string, args map[string]string) string {
+	var result strings.Builder
+
+	for i := 0; i < len(input); i++ {
+		if input[i] != '$' || i+1 == len(input) {
+			result.WriteByte(input[i])
+			continue
+		}
+
+		if input[i+1] == '{' {
+			end := dockerfileArgEnd(input[i:])
+			if end < 0 {
+				result.WriteString(input[i:])
+				break
+			}
+			expression := input[i+2 : i+end]
+			i += end
+
+			name, modifier, word := expression, "", ""
+			if j := strings.Index(expression, ":"); j >= 0 && j+1 < len(expression) {
+				name, modifier, word = expression[:j], expression[j:j+2], expression[j+2:]
+			}
+
+			value, ok := args[name]
+			switch modifier {
+			case ":-":
+				if !ok || value == "" {
+					value = expandDockerfileArgs(word, args)
+				}
+			case ":+":
+				if ok && value != "" {
+					value = expandDockerfileArgs(word, args)
+				}
+			}
+			result.WriteString(value)
+			continue
+		}
+
+		j := i + 1
+		for j < len(input) && (input[j] == '_' || input[j] >= 'a' && input[j] <= 'z' || input[j] >= 'A' && input[j] <= 'Z' || input[j] >= '0' && input[j] <= '9') {
+			j++
+		}
+		if j == i+1 {
+			result.WriteByte('$')
+			continue
+		}
+		result.WriteString(args[input[i+1:j]])
+		i = j - 1
+	}
+
+	return result.String()
+}
+
+// dockerfileArgEnd returns the index of the "}" closing the "${" at the start
+// of input, skipping nested "${...}" expressions, or -1 if it is not closed.
+func dockerfileArgEnd(input string) int {
+	depth := 0
+	for i := 0; i < len(input); i++ {
+		switch {
+		case input[i] == '$' && i+1 < len(input) && input[i+1] == '{':
+			depth++
+			i++
+		case input[i] == '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
@@ -0,0 +1,156 @@
+package generictypes
+
+import (
+	"strings"
+	"testing"
+)
+
+const testDockerfile = `# syntax=docker/dockerfile:1
+# escape=\
+
+ARG GO_VERSION=1.21
+ARG REGISTRY="quay.io"
+ARG BASE=${REGISTRY}/giantswarm/alpine
+ARG UNUSED
+
+FROM --platform=$BUILDPLATFORM golang:${GO_VERSION}-alpine AS Builder
+ARG GO_VERSION=ignored
+COPY --from=${REGISTRY}/giantswarm/tools:1.0 /bin/tool /bin/tool
+RUN go build ./...
+
+# The runtime image.
+from \
+    ${BASE}:${ALPINE_VERSION:-3.18} \
+    as runtime
+COPY --from=builder /go/bin/app /app
+COPY --from=0 /go/bin/app /app2
+COPY --chown=1000 --from=docker.io/library/busybox:1.36 /bin/sh /bin/sh
+
+FROM builder AS test
+FROM scratch
+COPY --from=runtime /app /app
+`
+
+var dockerfileImages = []struct {
+	BuildArgs map[string]string
+
+	ExpectedImages []string
+}{
+	{
+		nil,
+		[]string{
+			"FROM builder golang:1.21-alpine",
+			"COPY builder quay.io/giantswarm/tools:1.0",
+			"FROM runtime quay.io/giantswarm/alpine:3.18",
+			"COPY runtime docker.io/library/busybox:1.36",
+		},
+	},
+	{
+		map[string]string{"GO_VERSION": "1.22", "REGISTRY": "registry.giantswarm.io", "ALPINE_VERSION": "3.19"},
+		[]string{
+			"FROM builder golang:1.22-alpine",
+			"COPY builder registry.giantswarm.io/giantswarm/tools:1.0",
+			"FROM runtime registry.giantswarm.io/giantswarm/alpine:3.18",
+			"COPY runtime docker.io/library/busybox:1.36",
+		},
+	},
+}
+
+func TestParseDockerfileImages(t *testing.T) {
+	for _, data := range dockerfileImages {
+		images, imageErrors, err := ParseDockerfileImages(strings.NewReader(testDockerfile), data.BuildArgs)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if len(imageErrors) != 0 {
+			t.Fatalf("Expected no image errors, got %v", imageErrors)
+		}
+
+		var found []string
+		for _, image := range images {
+			found = append(found, image.Location.Kind+" "+image.Location.Name+" "+image.Image.String())
+		}
+		if strings.Join(found, "\n") != strings.Join(data.ExpectedImages, "\n") {
+			t.Fatalf("Expected images\n%s\ngot\n%s", strings.Join(data.ExpectedImages, "\n"), strings.Join(found, "\n"))
+		}
+	}
+}
+
+func TestParseDockerfileImagesLocation(t *testing.T) {
+	images, _, err := ParseDockerfileImages(strings.NewReader(testDockerfile), map[string]string{"BUILDPLATFORM": "linux/amd64"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if images[0].Platform != "linux/amd64" || images[0].Location.Line != 9 {
+		t.Fatalf("Unexpected builder image %#v", images[0])
+	}
+	if images[2].Platform != "" || images[2].Location.Line != 15 {
+		t.Fatalf("Unexpected runtime image %#v", images[2])
+	}
+}
+
+func TestParseDockerfileImagesErrors(t *testing.T) {
+	dockerfile := "FROM alpine\nFROM ${IMAGE}\nCOPY --from=Invalid/Image /a /b\n"
+
+	images, imageErrors, err := ParseDockerfileImages(strings.NewReader(dockerfile), nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(images) != 1 || images[0].Image.String() != "alpine" {
+		t.Fatalf("Unexpected images %#v", images)
+	}
+	if len(imageErrors) != 2 || imageErrors[0].Location.Line != 2 || imageErrors[1].Input != "Invalid/Image" {
+		t.Fatalf("Unexpected image errors %#v", imageErrors)
+	}
+
+	if _, _, err := ParseDockerfileImages(strings.NewReader("FROM --platform=linux/amd64\n"), nil); err == nil {
+		t.Fatalf("Expected error for FROM without image")
+	}
+}
+
+func TestParseDockerfileImagesEscapeDirective(t *testing.T) {
+	dockerfile := "# escape=`\nFROM mcr.microsoft.com/windows/servercore:ltsc2019 `\n  AS base\nCOPY --from=base C:\\app C:\\app\n"
+
+	images, _, err := ParseDockerfileImages(strings.NewReader(dockerfile), nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(images) != 1 || images[0].Location.Name != "base" {
+		t.Fatalf("Unexpected images %#v", images)
+	}
+}
+
+var dockerfileExpansions = []struct {
+	Input    string
+	Expected string
+}{
+	{"$A", "a"},
+	{"${A}", "a"},
+	{"$A$B", "ab"},
+	{"${A}-${B}", "a-b"},
+	{"${MISSING}", ""},
+	{"${MISSING:-default}", "default"},
+	{"${EMPTY:-default}", "default"},
+	{"${A:-default}", "a"},
+	{"${A:+alternative}", "alternative"},
+	{"${MISSING:+alternative}", ""},
+	{"${MISSING:-$A}", "a"},
+	{"${MISSING:-${B}}", "b"},
+	{"${A:-${B}}", "a"},
+	{"${MISSING:-${EMPTY:-${B}}}-c", "b-c"},
+	{"${A:+${B}x}", "bx"},
+	{"${MISSING:-${B}", "${MISSING:-${B}"},
+	{"price: 5$", "price: 5$"},
+	{"$-", "$-"},
+}
+
+func TestExpandDockerfileArgs(t *testing.T) {
+	args := map[string]string{"A": "a", "B": "b", "EMPTY": ""}
+
+	for _, data := range dockerfileExpansions {
+		if expanded := expandDockerfileArgs(data.Input, args); expanded != data.Expected {
+			t.Fatalf("Expected %#v to expand to %#v, got %#v", data.Input, data.Expected, expanded)
+		}
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/giantswarm/validate"
 	"github.com/giantswarm/validate/web"
 	"github.com/juju/errgo"
+	"gopkg.in/yaml.v3"
 )
 
 var (
@@ -59,6 +60,42 @@ func (d *Domain) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+// MarshalText implements encoding.TextMarshaler, so domains can be used e.g.
+// as JSON map keys.
+func (d Domain) MarshalText() ([]byte, error) {
+	return []byte(d), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler with the same validation
+// as UnmarshalJSON.
+func (d *Domain) UnmarshalText(data []byte) error {
+	domain := Domain(data)
+	if err := domain.Validate(); err != nil {
+		return err
+	}
+	*d = domain
+	return nil
+}
+
+// MarshalYAML implements yaml.Marshaler.
+func (d Domain) MarshalYAML() (interface{}, error) {
+	return string(d), nil
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler with the same validation as
+// UnmarshalJSON. Errors contain the line and column of the domain.
+func (d *Domain) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.ScalarNode || node.ShortTag() != "!!str" {
+		return yamlNodeError(node, errgo.Newf("Domain must be a string"))
+	}
+	domain := Domain(node.Value)
+	if err := domain.Validate(); err != nil {
+		return yamlNodeError(node, err)
+	}
+	*d = domain
+	return nil
+}
+
 func (d *Domain) String() string {
 	return string(*d)
 }
@@ -72,7 +109,7 @@ func (d *Domain) Validate() error {
 	v := validate.NewValidator()
 
 	if err := v.Validate(web.NewDomain(d.String())); err != nil {
-		return maskAny(errgo.Newf("Invalid domain: %s", d.String()))
+		return newDomainError(d.String())
 	}
 
 	return nil
@@ -1,9 +1,13 @@
 package generictypes_test
 
 import (
+	"encoding/json"
+	"encoding/xml"
+	"strings"
 	"testing"
 
 	"github.com/giantswarm/generic-types-go"
+	"gopkg.in/yaml.v3"
 )
 
 func TestDomainValidatorValidDomain(t *testing.T) {
@@ -29,3 +33,75 @@ func TestDomainValidatorInvalidDomainWithPort(t *testing.T) {
 		t.Fatalf("Invalid domain detected to be valid: %v", d.String())
 	}
 }
+
+func TestDomainTextMarshalling(t *testing.T) {
+	owners := map[generictypes.Domain]string{
+		generictypes.Domain("i.am.correct.com"): "me",
+	}
+
+	data, err := json.Marshal(owners)
+	if err != nil {
+		t.Fatalf("Failed to marshal domains: %v", err)
+	}
+	if string(data) != `{"i.am.correct.com":"me"}` {
+		t.Fatalf("Unexpected JSON %s", string(data))
+	}
+
+	var decoded map[generictypes.Domain]string
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Failed to unmarshal domains: %v", err)
+	}
+	if decoded[generictypes.Domain("i.am.correct.com")] != "me" {
+		t.Fatalf("Unexpected domains %v", decoded)
+	}
+
+	if err := json.Unmarshal([]byte(`{"i.$am.invalid.com":"me"}`), &decoded); err == nil {
+		t.Fatalf("Invalid domain key detected to be valid")
+	}
+}
+
+func TestDomainXMLAttribute(t *testing.T) {
+	var target struct {
+		Domain generictypes.Domain `xml:"domain,attr"`
+	}
+
+	if err := xml.Unmarshal([]byte(`<host domain="i.am.correct.com"></host>`), &target); err != nil {
+		t.Fatalf("Failed to unmarshal XML: %v", err)
+	}
+	if target.Domain != "i.am.correct.com" {
+		t.Fatalf("Unexpected domain '%s'", target.Domain)
+	}
+
+	if err := xml.Unmarshal([]byte(`<host domain="i.$am.invalid.com"></host>`), &target); err == nil {
+		t.Fatalf("Invalid domain detected to be valid")
+	}
+}
+
+func TestDomainYAML(t *testing.T) {
+	var config struct {
+		Domain generictypes.Domain `yaml:"domain"`
+	}
+
+	if err := yaml.Unmarshal([]byte("domain: i.am.correct.com\n"), &config); err != nil {
+		t.Fatalf("Failed to unmarshal YAML: %v", err)
+	}
+	if config.Domain != "i.am.correct.com" {
+		t.Fatalf("Unexpected domain '%s'", config.Domain)
+	}
+
+	output, err := yaml.Marshal(config)
+	if err != nil {
+		t.Fatalf("Failed to marshal YAML: %v", err)
+	}
+	if string(output) != "domain: i.am.correct.com\n" {
+		t.Fatalf("Unexpected YAML %s", string(output))
+	}
+
+	err = yaml.Unmarshal([]byte("name: test\ndomain: i.$am.invalid.com\n"), &config)
+	if err == nil {
+		t.Fatalf("Invalid domain detected to be valid")
+	}
+	if !strings.Contains(err.Error(), "Line 2, column 9: ") {
+		t.Fatalf("Expected error with line and column, got '%v'", err)
+	}
+}
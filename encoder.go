@@ -0,0 +1,163 @@
+package generictypes
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+)
+
+var dockerPortType = reflect.TypeOf(DockerPort{})
+
+// JSONEncoder is a json.Encoder that can canonicalize all ports of a
+// document:
+//
+//	enc := generictypes.NewJSONEncoder(os.Stdout)
+//	enc.SetPortFormat(generictypes.PortFormatDocker)
+//	err := enc.Encode(config)
+type JSONEncoder struct {
+	encoder    *json.Encoder
+	portFormat *PortFormat
+}
+
+// NewJSONEncoder returns an encoder that writes to w. Without
+// SetPortFormat, it behaves like json.NewEncoder.
+func NewJSONEncoder(w io.Writer) *JSONEncoder {
+	return &JSONEncoder{encoder: json.NewEncoder(w)}
+}
+
+// SetPortFormat makes the encoder marshal every DockerPort in the given
+// format, instead of the format of the port itself. Encode fails if a port
+// cannot be represented in the format. Ports used as map keys are always
+// formatted as "<port>/<protocol>".
+func (e *JSONEncoder) SetPortFormat(format PortFormat) {
+	e.portFormat = &format
+}
+
+// SetIndent behaves like json.Encoder.SetIndent.
+func (e *JSONEncoder) SetIndent(prefix, indent string) {
+	e.encoder.SetIndent(prefix, indent)
+}
+
+// SetEscapeHTML behaves like json.Encoder.SetEscapeHTML.
+func (e *JSONEncoder) SetEscapeHTML(on bool) {
+	e.encoder.SetEscapeHTML(on)
+}
+
+// Encode writes the JSON encoding of v followed by a newline. v itself is
+// not modified, ports are reformatted in a copy.
+func (e *JSONEncoder) Encode(v interface{}) error {
+	if e.portFormat != nil && v != nil {
+		formatted, err := formatPorts(reflect.ValueOf(v), *e.portFormat, "")
+		if err != nil {
+			return err
+		}
+		v = formatted.Interface()
+	}
+	if err := e.encoder.Encode(v); err != nil {
+		return maskAny(err)
+	}
+	return nil
+}
+
+// formatPorts returns a copy of v with all non-empty ports in exported fields,
+// pointers, interfaces, slices, arrays and map values set to the given format.
+// v must not contain pointer cycles, which json.Marshal rejects anyway.
+func formatPorts(v reflect.Value, format PortFormat, path string) (reflect.Value, error) {
+	if v.Type() == dockerPortType {
+		port := v.Interface().(DockerPort)
+		if port.Empty() {
+			return v, nil
+		}
+		formatted, err := port.WithFormat(format)
+		if err != nil {
+			if path == "" {
+				return v, err
+			}
+			return v, FieldError{Path: path, Err: err}
+		}
+		return reflect.ValueOf(formatted), nil
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return v, nil
+		}
+		elem, err := formatPorts(v.Elem(), format, path)
+		if err != nil {
+			return v, err
+		}
+		ptr := reflect.New(v.Type().Elem())
+		ptr.Elem().Set(elem)
+		return ptr, nil
+	case reflect.Interface:
+		if v.IsNil() {
+			return v, nil
+		}
+		elem, err := formatPorts(v.Elem(), format, path)
+		if err != nil {
+			return v, err
+		}
+		out := reflect.New(v.Type()).Elem()
+		out.Set(elem)
+		return out, nil
+	case reflect.Struct:
+		out := reflect.New(v.Type()).Elem()
+		out.Set(v)
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			if field.PkgPath != "" {
+				continue // unexported
+			}
+			fieldPath := fieldName(field)
+			if path != "" {
+				fieldPath = path + "." + fieldPath
+			}
+			formatted, err := formatPorts(v.Field(i), format, fieldPath)
+			if err != nil {
+				return v, err
+			}
+			out.Field(i).Set(formatted)
+		}
+		return out, nil
+	case reflect.Slice:
+		if v.IsNil() {
+			return v, nil
+		}
+		out := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			formatted, err := formatPorts(v.Index(i), format, fmt.Sprintf("%s[%d]", path, i))
+			if err != nil {
+				return v, err
+			}
+			out.Index(i).Set(formatted)
+		}
+		return out, nil
+	case reflect.Array:
+		out := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.Len(); i++ {
+			formatted, err := formatPorts(v.Index(i), format, fmt.Sprintf("%s[%d]", path, i))
+			if err != nil {
+				return v, err
+			}
+			out.Index(i).Set(formatted)
+		}
+		return out, nil
+	case reflect.Map:
+		if v.IsNil() {
+			return v, nil
+		}
+		out := reflect.MakeMapWithSize(v.Type(), v.Len())
+		for _, key := range v.MapKeys() {
+			formatted, err := formatPorts(v.MapIndex(key), format, fmt.Sprintf("%s[%v]", path, key.Interface()))
+			if err != nil {
+				return v, err
+			}
+			out.SetMapIndex(key, formatted)
+		}
+		return out, nil
+	default:
+		return v, nil
+	}
+}
@@ -0,0 +1,80 @@
+package generictypes
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+type encoderService struct {
+	Port    DockerPort            `json:"port"`
+	Ports   []DockerPort          `json:"ports"`
+	Named   map[string]DockerPort `json:"named,omitempty"`
+	Backup  *DockerPort           `json:"backup,omitempty"`
+	Extra   interface{}           `json:"extra,omitempty"`
+	Unset   DockerPort            `json:"unset"`
+	private DockerPort
+}
+
+func TestJSONEncoder_SetPortFormat(t *testing.T) {
+	backup := MustParseDockerPort("8443")
+	service := encoderService{
+		Port:    MustParseDockerPort("80/tcp"),
+		Ports:   []DockerPort{MustParseDockerPort("443"), {Port: "8080", Protocol: ProtocolTCP}},
+		Named:   map[string]DockerPort{"http": MustParseDockerPort("80")},
+		Backup:  &backup,
+		Extra:   MustParseDockerPort("9090/tcp"),
+		private: MustParseDockerPort("1/udp"),
+	}
+
+	var formats = []struct {
+		Format   PortFormat
+		Expected string
+	}{
+		{PortFormatDocker, `{"port":"80/tcp","ports":["443/tcp","8080/tcp"],"named":{"http":"80/tcp"},"backup":"8443/tcp","extra":"9090/tcp","unset":"/"}`},
+		{PortFormatNumber, `{"port":80,"ports":[443,8080],"named":{"http":80},"backup":8443,"extra":9090,"unset":"/"}`},
+		{PortFormatString, `{"port":"80","ports":["443","8080"],"named":{"http":"80"},"backup":"8443","extra":"9090","unset":"/"}`},
+	}
+
+	for _, data := range formats {
+		var buf bytes.Buffer
+		encoder := NewJSONEncoder(&buf)
+		encoder.SetPortFormat(data.Format)
+		if err := encoder.Encode(service); err != nil {
+			t.Fatalf("Expected no error for format %s, got %v", data.Format, err)
+		}
+		if strings.TrimSpace(buf.String()) != data.Expected {
+			t.Fatalf("Expected %s for format %s, got %s", data.Expected, data.Format, buf.String())
+		}
+	}
+
+	// The original value keeps its formats.
+	if service.Ports[0].Format() != PortFormatString || backup.Format() != PortFormatString {
+		t.Fatalf("Expected original ports to be unchanged")
+	}
+}
+
+func TestJSONEncoder_UDP(t *testing.T) {
+	service := encoderService{Ports: []DockerPort{MustParseDockerPort("80"), MustParseDockerPort("53/udp")}}
+
+	var buf bytes.Buffer
+	encoder := NewJSONEncoder(&buf)
+	encoder.SetPortFormat(PortFormatNumber)
+	err := encoder.Encode(&service)
+	if !errors.Is(err, ErrUnsupportedPortFormat) {
+		t.Fatalf("Expected ErrUnsupportedPortFormat, got %v", err)
+	}
+	if !strings.HasPrefix(err.Error(), "ports[1]: Port 53/udp cannot be formatted as number") {
+		t.Fatalf("Unexpected error message '%v'", err)
+	}
+
+	buf.Reset()
+	encoder = NewJSONEncoder(&buf)
+	if err := encoder.Encode(&service); err != nil {
+		t.Fatalf("Expected no error without port format, got %v", err)
+	}
+	if !strings.Contains(buf.String(), `"ports":["80","53/udp"]`) {
+		t.Fatalf("Expected ports in their own format, got %s", buf.String())
+	}
+}
@@ -0,0 +1,161 @@
+package generictypes
+
+import (
+	"github.com/juju/errgo"
+
+	"fmt"
+)
+
+var (
+	// ErrInvalidPort matches all port parsing errors with errors.Is, see
+	// PortError.Is. errgo.Cause returns the specific ErrInvalidPortFormat,
+	// ErrInvalidPortNumber or ErrInvalidProtocol instead.
+	ErrInvalidPort       = errgo.New("Not a valid port. Format: <port>[/<protocol>]")
+	ErrInvalidPortFormat = errgo.New("Invalid port format")
+	ErrInvalidPortNumber = errgo.New("Invalid port number")
+	ErrInvalidProtocol   = errgo.New("Invalid protocol")
+
+	// ErrUnsupportedPortFormat is returned when marshalling a port in a
+	// PortFormat that cannot represent it.
+	ErrUnsupportedPortFormat = errgo.New("Unsupported port format")
+
+	// ErrDuplicatePort is returned if a port is given twice, e.g. as "80" and
+	// "80/tcp".
+	ErrDuplicatePort = errgo.New("Duplicate port")
+
+	ErrInvalidDomain = errgo.New("Not a valid domain")
+)
+
+// typedError is embedded by the error types of this package. Err is one of
+// the exported Err* sentinels and is returned by errgo.Cause as well as
+// matched by errors.Is.
+type typedError struct {
+	Input   string // The input that failed to parse or validate
+	Err     error  // The sentinel error
+	Message string
+}
+
+func (e *typedError) Error() string {
+	return e.Message
+}
+
+// Cause implements errgo.Causer.
+func (e *typedError) Cause() error {
+	return e.Err
+}
+
+func (e *typedError) Unwrap() error {
+	return e.Err
+}
+
+// ImageError is returned for invalid images. Err is ErrInvalidFormat.
+type ImageError struct {
+	typedError
+}
+
+func newImageError(input, format string, args ...interface{}) error {
+	return &ImageError{typedError{
+		Input:   input,
+		Err:     ErrInvalidFormat,
+		Message: fmt.Sprintf(format, args...) + ": " + ErrInvalidFormat.Error(),
+	}}
+}
+
+// PortError is returned for invalid ports. Err is ErrInvalidPortFormat,
+// ErrInvalidPortNumber or ErrInvalidProtocol, all of which also match
+// ErrInvalidPort with errors.Is, ErrUnsupportedPortFormat if the port
+// cannot be marshalled in the requested format, ErrDuplicatePort,
+// ErrInvalidPortBinding, ErrUnknownService, or one of the PortAllocator
+// errors ErrInvalidPortRange, ErrPortConflict and ErrNoFreePort.
+type PortError struct {
+	typedError
+}
+
+func newPortError(input string, err error, format string, args ...interface{}) error {
+	return &PortError{typedError{
+		Input:   input,
+		Err:     err,
+		Message: fmt.Sprintf(format, args...),
+	}}
+}
+
+func (e *PortError) Is(target error) bool {
+	switch e.Err {
+	case ErrInvalidPortFormat, ErrInvalidPortNumber, ErrInvalidProtocol:
+		return target == ErrInvalidPort
+	default:
+		return false
+	}
+}
+
+// DomainError is returned for invalid domains. Err is ErrInvalidDomain.
+type DomainError struct {
+	typedError
+}
+
+func newDomainError(input string) error {
+	return &DomainError{typedError{
+		Input:   input,
+		Err:     ErrInvalidDomain,
+		Message: fmt.Sprintf("Invalid domain: %s", input),
+	}}
+}
+
+// PlatformError is returned for invalid platforms. Err is ErrInvalidPlatform.
+type PlatformError struct {
+	typedError
+}
+
+func newPlatformError(input, format string, args ...interface{}) error {
+	return &PlatformError{typedError{
+		Input:   input,
+		Err:     ErrInvalidPlatform,
+		Message: fmt.Sprintf(format, args...) + ": " + ErrInvalidPlatform.Error(),
+	}}
+}
+
+// noteError prefixes an error with a message like errgo.Notef, but keeps it
+// available to errgo.Cause, errors.Is and errors.As, so that the typed errors
+// and Err* sentinels of this package survive being annotated.
+type noteError struct {
+	Message string
+	Err     error
+}
+
+func notef(err error, format string, args ...interface{}) error {
+	return &noteError{Message: fmt.Sprintf(format, args...), Err: err}
+}
+
+func (e *noteError) Error() string {
+	return e.Message + ": " + e.Err.Error()
+}
+
+// Cause implements errgo.Causer.
+func (e *noteError) Cause() error {
+	return errgo.Cause(e.Err)
+}
+
+func (e *noteError) Unwrap() error {
+	return e.Err
+}
+
+// yamlError prefixes an error with the line and column of a YAML node,
+// keeping it available to errgo.Cause, errors.Is and errors.As.
+type yamlError struct {
+	Line   int
+	Column int
+	Err    error
+}
+
+func (e *yamlError) Error() string {
+	return fmt.Sprintf("Line %d, column %d: %v", e.Line, e.Column, e.Err)
+}
+
+// Cause implements errgo.Causer.
+func (e *yamlError) Cause() error {
+	return errgo.Cause(e.Err)
+}
+
+func (e *yamlError) Unwrap() error {
+	return e.Err
+}
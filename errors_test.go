@@ -0,0 +1,223 @@
+package generictypes
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/juju/errgo"
+	"gopkg.in/yaml.v3"
+)
+
+var portErrors = []struct {
+	Input    string
+	Expected error
+}{
+	{"a/b/c", ErrInvalidPortFormat},
+	{"a/tcp", ErrInvalidPortNumber},
+	{"0/tcp", ErrInvalidPortNumber},
+	{"66000/udp", ErrInvalidPortNumber},
+	{"80/", ErrInvalidProtocol},
+	{"90/icmp", ErrInvalidProtocol},
+}
+
+func TestPortErrors(t *testing.T) {
+	for _, data := range portErrors {
+		_, err := ParseDockerPort(data.Input)
+		if !errors.Is(err, data.Expected) {
+			t.Fatalf("Expected %v for '%s', got %v", data.Expected, data.Input, err)
+		}
+		if !errors.Is(err, ErrInvalidPort) {
+			t.Fatalf("Expected ErrInvalidPort for '%s', got %v", data.Input, err)
+		}
+		if errgo.Cause(err) != data.Expected {
+			t.Fatalf("Expected cause %v for '%s', got %v", data.Expected, data.Input, errgo.Cause(err))
+		}
+
+		var portErr *PortError
+		if !errors.As(err, &portErr) || portErr.Input != data.Input {
+			t.Fatalf("Expected PortError for '%s', got %#v", data.Input, err)
+		}
+	}
+}
+
+func TestPortErrorsThroughDecoding(t *testing.T) {
+	var port DockerPort
+
+	err := json.Unmarshal([]byte(`"80/icmp"`), &port)
+	if !errors.Is(err, ErrInvalidProtocol) {
+		t.Fatalf("Expected ErrInvalidProtocol from JSON, got %v", err)
+	}
+
+	err = yaml.Unmarshal([]byte("port: 0\n"), &struct {
+		Port DockerPort `yaml:"port"`
+	}{})
+	if !errors.Is(err, ErrInvalidPortNumber) {
+		t.Fatalf("Expected ErrInvalidPortNumber from YAML, got %v", err)
+	}
+
+	var ports DockerPortSlice
+	if err := ports.Set("80,70000"); !errors.Is(err, ErrInvalidPortNumber) {
+		t.Fatalf("Expected ErrInvalidPortNumber from flag, got %v", err)
+	}
+}
+
+func TestPortErrorsThroughEntryPoints(t *testing.T) {
+	var entryPoints = []struct {
+		Name     string
+		Parse    func() error
+		Expected error
+	}{
+		{"ParsePortBindings", func() error {
+			_, err := ParsePortBindings("8080:abc")
+			return err
+		}, ErrInvalidPortNumber},
+		{"ParsePortBindings host IP", func() error {
+			_, err := ParsePortBindings("[::1:80")
+			return err
+		}, ErrInvalidPortBinding},
+		{"ParsePortBindings format", func() error {
+			_, err := ParsePortBindings("1:2:3:4")
+			return err
+		}, ErrInvalidPortFormat},
+		{"LoadComposeFile ports", func() error {
+			_, _, err := LoadComposeFile(strings.NewReader("services:\n  api:\n    ports:\n      - 8080:80/icmp\n"))
+			return err
+		}, ErrInvalidProtocol},
+		{"LoadComposeFile published", func() error {
+			_, _, err := LoadComposeFile(strings.NewReader("services:\n  api:\n    ports:\n      - 70000:80\n"))
+			return err
+		}, ErrInvalidPortNumber},
+		{"LoadComposeFile expose", func() error {
+			_, _, err := LoadComposeFile(strings.NewReader("services:\n  api:\n    expose:\n      - 90-80\n"))
+			return err
+		}, ErrInvalidPortRange},
+		{"ParseServices", func() error {
+			_, err := ParseServices(strings.NewReader("http 0/tcp\n"))
+			return err
+		}, ErrInvalidPortNumber},
+	}
+	for _, data := range entryPoints {
+		err := data.Parse()
+		if !errors.Is(err, data.Expected) {
+			t.Fatalf("Expected %v from %s, got %v", data.Expected, data.Name, err)
+		}
+		if errgo.Cause(err) != data.Expected {
+			t.Fatalf("Expected cause %v from %s, got %v", data.Expected, data.Name, errgo.Cause(err))
+		}
+		var portErr *PortError
+		if !errors.As(err, &portErr) {
+			t.Fatalf("Expected PortError from %s, got %#v", data.Name, err)
+		}
+	}
+}
+
+func TestImageErrorsThroughEntryPoints(t *testing.T) {
+	invalid := DockerImage{Repository: "UPPER"}
+
+	compose, _, err := LoadComposeFile(strings.NewReader("services:\n  api:\n    image: redis\n"))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	var imageErr *ImageError
+	if err := compose.SetImage("api", invalid); !errors.Is(err, ErrInvalidFormat) || !errors.As(err, &imageErr) {
+		t.Fatalf("Expected ImageError from ComposeFile.SetImage, got %#v", err)
+	}
+
+	values, _, err := LoadHelmValues(strings.NewReader("image:\n  repository: redis\n"))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := values.SetImage("image", invalid); !errors.Is(err, ErrInvalidFormat) || !errors.As(err, &imageErr) {
+		t.Fatalf("Expected ImageError from HelmValues.SetImage, got %#v", err)
+	}
+}
+
+func TestSentinelErrorsThroughEntryPoints(t *testing.T) {
+	_, _, err := LoadComposeFile(strings.NewReader("services: [api]\n"))
+	if !errors.Is(err, ErrInvalidComposeFile) || errgo.Cause(err) != ErrInvalidComposeFile {
+		t.Fatalf("Expected ErrInvalidComposeFile, got %v", err)
+	}
+
+	compose, _, err := LoadComposeFile(strings.NewReader("services:\n  api:\n    image: redis\n"))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := compose.SetImage("unknown", MustParseDockerImage("redis")); !errors.Is(err, ErrInvalidComposeFile) {
+		t.Fatalf("Expected ErrInvalidComposeFile for unknown service, got %v", err)
+	}
+
+	var target struct{}
+	if _, err := CheckJSONRoundTrip([]byte("{}"), target); !errors.Is(err, ErrInvalidTarget) || errgo.Cause(err) != ErrInvalidTarget {
+		t.Fatalf("Expected ErrInvalidTarget, got %v", err)
+	}
+	var config struct{ Port DockerPort }
+	if _, err := CheckJSONRoundTrip([]byte(`{"Port":"80/icmp"}`), &config); !errors.Is(err, ErrInvalidProtocol) {
+		t.Fatalf("Expected ErrInvalidProtocol from CheckJSONRoundTrip, got %v", err)
+	}
+}
+
+func TestImageErrors(t *testing.T) {
+	var inputs = []string{"", "foo/image", "redis@sha256", "UPPER"}
+
+	for _, input := range inputs {
+		_, err := ParseDockerImage(input)
+		if !errors.Is(err, ErrInvalidFormat) {
+			t.Fatalf("Expected ErrInvalidFormat for '%s', got %v", input, err)
+		}
+		if errgo.Cause(err) != ErrInvalidFormat {
+			t.Fatalf("Expected cause ErrInvalidFormat for '%s', got %v", input, errgo.Cause(err))
+		}
+
+		var imageErr *ImageError
+		if !errors.As(err, &imageErr) || imageErr.Input != input {
+			t.Fatalf("Expected ImageError for '%s', got %#v", input, err)
+		}
+	}
+
+	_, err := MustParseDockerImage("redis").WithRegistry("localhost")
+	if !errors.Is(err, ErrInvalidFormat) {
+		t.Fatalf("Expected ErrInvalidFormat for ambiguous image, got %v", err)
+	}
+}
+
+func TestDomainAndPlatformErrors(t *testing.T) {
+	domain := Domain("i.$am.invalid.com")
+	err := domain.Validate()
+	if !errors.Is(err, ErrInvalidDomain) || errgo.Cause(err) != ErrInvalidDomain {
+		t.Fatalf("Expected ErrInvalidDomain, got %v", err)
+	}
+	var domainErr *DomainError
+	if !errors.As(err, &domainErr) || domainErr.Input != "i.$am.invalid.com" {
+		t.Fatalf("Expected DomainError, got %#v", err)
+	}
+
+	_, err = ParsePlatform("linux/sparc")
+	if !errors.Is(err, ErrInvalidPlatform) || errgo.Cause(err) != ErrInvalidPlatform {
+		t.Fatalf("Expected ErrInvalidPlatform, got %v", err)
+	}
+	var platformErr *PlatformError
+	if !errors.As(err, &platformErr) || platformErr.Input != "linux/sparc" {
+		t.Fatalf("Expected PlatformError, got %#v", err)
+	}
+}
+
+func TestValidationErrorsMatch(t *testing.T) {
+	config := struct {
+		Image DockerImage
+		Ports []DockerPort
+	}{
+		Image: DockerImage{Repository: "redis"},
+		Ports: []DockerPort{{Port: "80", Protocol: "icmp"}},
+	}
+
+	err := ValidateStruct(config)
+	if !errors.Is(err, ErrInvalidProtocol) {
+		t.Fatalf("Expected ErrInvalidProtocol, got %v", err)
+	}
+	var fieldErr FieldError
+	if !errors.As(err, &fieldErr) || fieldErr.Path != "Ports[0]" {
+		t.Fatalf("Expected FieldError for Ports[0], got %#v", err)
+	}
+}
@@ -0,0 +1,141 @@
+package generictypes
+
+import (
+	"strings"
+)
+
+// Set implements flag.Value, so images can be used as command line flags:
+//
+//	var image generictypes.DockerImage
+//	flag.Var(&image, "image", "The image to run")
+func (img *DockerImage) Set(value string) error {
+	return img.UnmarshalText([]byte(value))
+}
+
+// Type returns the type name spf13/pflag shows in the usage message.
+func (img *DockerImage) Type() string {
+	return "image"
+}
+
+// Set implements flag.Value, accepting "<port>" and "<port>/<protocol>".
+func (d *DockerPort) Set(value string) error {
+	return d.UnmarshalText([]byte(value))
+}
+
+// Type returns the type name spf13/pflag shows in the usage message.
+func (d *DockerPort) Type() string {
+	return "port"
+}
+
+// Set implements flag.Value.
+func (d *Domain) Set(value string) error {
+	return d.UnmarshalText([]byte(value))
+}
+
+// Type returns the type name spf13/pflag shows in the usage message.
+func (d *Domain) Type() string {
+	return "domain"
+}
+
+// DockerImageSlice is a flag.Value for a list of images. Every use of the
+// flag adds to the list, and a single use may hold comma separated images,
+// e.g. "--image redis --image nginx,alpine".
+type DockerImageSlice []DockerImage
+
+func (s *DockerImageSlice) String() string {
+	items := make([]string, len(*s))
+	for i, img := range *s {
+		items[i] = img.String()
+	}
+	return strings.Join(items, ",")
+}
+
+// Set implements flag.Value. Nothing is added if one of the images is invalid.
+func (s *DockerImageSlice) Set(value string) error {
+	var images []DockerImage
+	for _, item := range splitFlagValue(value) {
+		var img DockerImage
+		if err := img.Set(item); err != nil {
+			return err
+		}
+		images = append(images, img)
+	}
+	*s = append(*s, images...)
+	return nil
+}
+
+// Type returns the type name spf13/pflag shows in the usage message.
+func (s *DockerImageSlice) Type() string {
+	return "imageSlice"
+}
+
+// DockerPortSlice is a flag.Value for a list of ports, see DockerImageSlice.
+type DockerPortSlice []DockerPort
+
+func (s *DockerPortSlice) String() string {
+	items := make([]string, len(*s))
+	for i, port := range *s {
+		items[i] = port.String()
+	}
+	return strings.Join(items, ",")
+}
+
+// Set implements flag.Value. Nothing is added if one of the ports is invalid.
+func (s *DockerPortSlice) Set(value string) error {
+	var ports []DockerPort
+	for _, item := range splitFlagValue(value) {
+		var port DockerPort
+		if err := port.Set(item); err != nil {
+			return err
+		}
+		ports = append(ports, port)
+	}
+	*s = append(*s, ports...)
+	return nil
+}
+
+// Type returns the type name spf13/pflag shows in the usage message.
+func (s *DockerPortSlice) Type() string {
+	return "portSlice"
+}
+
+// DomainSlice is a flag.Value for a list of domains, see DockerImageSlice.
+type DomainSlice []Domain
+
+func (s *DomainSlice) String() string {
+	items := make([]string, len(*s))
+	for i, domain := range *s {
+		items[i] = string(domain)
+	}
+	return strings.Join(items, ",")
+}
+
+// Set implements flag.Value. Nothing is added if one of the domains is
+// invalid.
+func (s *DomainSlice) Set(value string) error {
+	var domains []Domain
+	for _, item := range splitFlagValue(value) {
+		var domain Domain
+		if err := domain.Set(item); err != nil {
+			return err
+		}
+		domains = append(domains, domain)
+	}
+	*s = append(*s, domains...)
+	return nil
+}
+
+// Type returns the type name spf13/pflag shows in the usage message.
+func (s *DomainSlice) Type() string {
+	return "domainSlice"
+}
+
+// splitFlagValue splits a comma separated flag value, trimming whitespace
+// around the items. Empty items are kept, so that they fail validation.
+func splitFlagValue(value string) []string {
+	items := strings.Split(value, ",")
+	for i, item := range items {
+		items[i] = strings.TrimSpace(item)
+	}
+	return items
+}
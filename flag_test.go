@@ -0,0 +1,135 @@
+package generictypes
+
+import (
+	"flag"
+	"io"
+	"strings"
+	"testing"
+)
+
+func newTestFlagSet() *flag.FlagSet {
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	return flags
+}
+
+func TestFlags(t *testing.T) {
+	var (
+		image  DockerImage
+		port   DockerPort
+		domain Domain
+	)
+	flags := newTestFlagSet()
+	flags.Var(&image, "image", "")
+	flags.Var(&port, "port", "")
+	flags.Var(&domain, "domain", "")
+
+	if err := flags.Parse([]string{"--image", "quay.io/giantswarm/app:1.0", "--port=8080", "--domain", "i.am.correct.com"}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if image.String() != "quay.io/giantswarm/app:1.0" {
+		t.Fatalf("Unexpected image '%s'", image.String())
+	}
+	if port != MustParseDockerPort("8080/tcp") {
+		t.Fatalf("Unexpected port '%s'", port.String())
+	}
+	if domain != "i.am.correct.com" {
+		t.Fatalf("Unexpected domain '%s'", domain.String())
+	}
+}
+
+var invalidFlags = []struct {
+	Args []string
+}{
+	{[]string{"--image", "foo/image"}},
+	{[]string{"--port", "80/icmp"}},
+	{[]string{"--domain", "i.$am.invalid.com"}},
+	{[]string{"--images", "redis,foo/image"}},
+	{[]string{"--ports", "80,"}},
+	{[]string{"--domains", "i.$am.invalid.com"}},
+}
+
+func TestFlagErrors(t *testing.T) {
+	for _, data := range invalidFlags {
+		var (
+			image   DockerImage
+			port    DockerPort
+			domain  Domain
+			images  DockerImageSlice
+			ports   DockerPortSlice
+			domains DomainSlice
+		)
+		flags := newTestFlagSet()
+		flags.Var(&image, "image", "")
+		flags.Var(&port, "port", "")
+		flags.Var(&domain, "domain", "")
+		flags.Var(&images, "images", "")
+		flags.Var(&ports, "ports", "")
+		flags.Var(&domains, "domains", "")
+
+		err := flags.Parse(data.Args)
+		if err == nil {
+			t.Fatalf("Expected error for %v", data.Args)
+		}
+		if !strings.Contains(err.Error(), "invalid value") {
+			t.Fatalf("Expected flag error for %v, got %v", data.Args, err)
+		}
+		if len(images) != 0 || len(ports) != 0 || len(domains) != 0 {
+			t.Fatalf("Expected no values to be added for %v", data.Args)
+		}
+	}
+}
+
+func TestSliceFlags(t *testing.T) {
+	var (
+		images  DockerImageSlice
+		ports   DockerPortSlice
+		domains DomainSlice
+	)
+	flags := newTestFlagSet()
+	flags.Var(&images, "image", "")
+	flags.Var(&ports, "port", "")
+	flags.Var(&domains, "domain", "")
+
+	args := []string{
+		"--image", "redis", "--image", "nginx:1.25, alpine",
+		"--port", "80,443", "--port", "53/udp",
+		"--domain", "i.am.correct.com",
+	}
+	if err := flags.Parse(args); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if images.String() != "redis,nginx:1.25,alpine" {
+		t.Fatalf("Unexpected images '%s'", images.String())
+	}
+	if ports.String() != "80/tcp,443/tcp,53/udp" {
+		t.Fatalf("Unexpected ports '%s'", ports.String())
+	}
+	if domains.String() != "i.am.correct.com" {
+		t.Fatalf("Unexpected domains '%s'", domains.String())
+	}
+}
+
+func TestFlagTypes(t *testing.T) {
+	var types = []struct {
+		Value interface{ Type() string }
+		Type  string
+	}{
+		{&DockerImage{}, "image"},
+		{&DockerPort{}, "port"},
+		{new(Domain), "domain"},
+		{&DockerImageSlice{}, "imageSlice"},
+		{&DockerPortSlice{}, "portSlice"},
+		{&DomainSlice{}, "domainSlice"},
+	}
+
+	for _, data := range types {
+		if _, ok := data.Value.(flag.Value); !ok {
+			t.Fatalf("Expected %T to implement flag.Value", data.Value)
+		}
+		if data.Value.Type() != data.Type {
+			t.Fatalf("Expected type '%s' for %T, got '%s'", data.Type, data.Value, data.Value.Type())
+		}
+	}
+}
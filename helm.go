@@ -0,0 +1,314 @@
+package generictypes
+
+import (
+	"github.com/juju/errgo"
+	"gopkg.in/yaml.v3"
+
+	"fmt"
+	"io"
+	"strings"
+)
+
+var (
+	ErrInvalidHelmValues = errgo.New("Invalid helm values")
+)
+
+// helmImageKeys lists the keys charts commonly use for each image part, in
+// order of preference. The name and version keys are only used in mappings
+// stored below a key containing "image", since e.g. chart dependencies have
+// them as well.
+var helmImageKeys = struct {
+	Registry   []string
+	Repository []string
+	Tag        []string
+	Digest     []string
+
+	ImageRepository []string
+	ImageTag        []string
+}{
+	Registry:   []string{"registry", "imageRegistry"},
+	Repository: []string{"repository", "image"},
+	Tag:        []string{"tag"},
+	Digest:     []string{"digest", "sha"},
+
+	ImageRepository: []string{"repository", "name", "image"},
+	ImageTag:        []string{"tag", "version"},
+}
+
+// HelmValues is a Helm values file whose images can be read and rewritten
+// while preserving comments, key order and formatting of the original file.
+type HelmValues struct {
+	Images []HelmImage
+
+	source []byte
+	root   *yaml.Node
+}
+
+// HelmImage is an image assembled from the split keys of a Helm values file,
+// e.g.
+//
+//	image:
+//	  registry: quay.io
+//	  repository: giantswarm/app
+//	  tag: 1.0.0
+//
+// Location.Path is the path of the image mapping, e.g. "controller.image".
+type HelmImage struct {
+	Image    DockerImage
+	Location ImageLocation
+
+	// The keys the image parts were read from, empty if not set.
+	RegistryKey   string
+	RepositoryKey string
+	TagKey        string
+	DigestKey     string
+}
+
+// LoadHelmValues reads a Helm values file and returns the images it finds in
+// mappings with a repository key, which are either stored below a key
+// containing "image" or have a tag or digest key. Images that cannot be
+// parsed are returned as errors instead of aborting.
+func LoadHelmValues(r io.Reader) (*HelmValues, []LocatedImageError, error) {
+	source, err := io.ReadAll(r)
+	if err != nil {
+		return nil, nil, maskAny(err)
+	}
+
+	v := &HelmValues{}
+	imageErrors, err := v.load(source)
+	if err != nil {
+		return nil, nil, err
+	}
+	return v, imageErrors, nil
+}
+
+// Bytes returns the values file including all rewritten images.
+func (v *HelmValues) Bytes() []byte {
+	return append([]byte{}, v.source...)
+}
+
+// Image returns the image found at the given path.
+func (v *HelmValues) Image(path string) (HelmImage, bool) {
+	for _, image := range v.Images {
+		if image.Location.Path == path {
+			return image, true
+		}
+	}
+	return HelmImage{}, false
+}
+
+// SetImage writes the given image into the split keys of the image mapping at
+// the given path. If the mapping has no registry key, the registry becomes
+// part of the repository. Missing tag and digest keys are added if the image
+// has a version or digest.
+func (v *HelmValues) SetImage(path string, img DockerImage) error {
+	if err := img.Validate(); err != nil {
+		return err
+	}
+
+	mapping := yamlNodeAtPath(v.root, path)
+	if mapping == nil {
+		return notef(ErrInvalidHelmValues, "No image mapping at %#v", path)
+	}
+	key := path[strings.LastIndex(path, ".")+1:]
+	if i := strings.Index(key, "["); i >= 0 {
+		key = key[:i]
+	}
+	keys, ok := helmImageMapping(mapping, key)
+	if !ok {
+		return notef(ErrInvalidHelmValues, "No image mapping at %#v", path)
+	}
+
+	repository := img.UnversionedString()
+	if keys.RegistryKey != "" {
+		repository = strings.TrimPrefix(repository, img.Registry+"/")
+	}
+
+	var edits []yamlEdit
+	set := func(key, fallbackKey, value string) error {
+		var (
+			edit yamlEdit
+			err  error
+		)
+		if key != "" {
+			edit, err = yamlReplaceScalar(v.source, yamlMappingValue(mapping, key), value)
+		} else if value != "" {
+			edit, err = yamlInsertKey(v.source, mapping, fallbackKey, value)
+		} else {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		edits = append(edits, edit)
+		return nil
+	}
+
+	if keys.RegistryKey != "" {
+		if err := set(keys.RegistryKey, "", img.Registry); err != nil {
+			return err
+		}
+	}
+	if err := set(keys.RepositoryKey, "", repository); err != nil {
+		return err
+	}
+	if err := set(keys.TagKey, "tag", img.Version); err != nil {
+		return err
+	}
+	if err := set(keys.DigestKey, "digest", img.Digest); err != nil {
+		return err
+	}
+
+	// Reload, so that node positions and images match the new source.
+	updated := &HelmValues{}
+	if _, err := updated.load(applyYAMLEdits(v.source, edits)); err != nil {
+		return err
+	}
+	*v = *updated
+	return nil
+}
+
+func (v *HelmValues) load(source []byte) ([]LocatedImageError, error) {
+	var document yaml.Node
+	if err := yaml.Unmarshal(source, &document); err != nil {
+		return nil, notef(ErrInvalidHelmValues, "%v", err)
+	}
+
+	v.source = source
+	v.root = nil
+	v.Images = nil
+	if len(document.Content) == 0 {
+		return nil, nil
+	}
+	v.root = document.Content[0]
+
+	var imageErrors []LocatedImageError
+	v.walk(v.root, "", "", &imageErrors)
+	return imageErrors, nil
+}
+
+// walk collects the images of all mappings below the given node.
+func (v *HelmValues) walk(node *yaml.Node, key, path string, imageErrors *[]LocatedImageError) {
+	switch node.Kind {
+	case yaml.SequenceNode:
+		for i, item := range node.Content {
+			v.walk(item, key, fmt.Sprintf("%s[%d]", path, i), imageErrors)
+		}
+	case yaml.MappingNode:
+		if keys, ok := helmImageMapping(node, key); ok {
+			v.addImage(node, keys, path, imageErrors)
+			return
+		}
+
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			childKey := node.Content[i].Value
+			childPath := childKey
+			if path != "" {
+				childPath = path + "." + childKey
+			}
+			v.walk(node.Content[i+1], childKey, childPath, imageErrors)
+		}
+	}
+}
+
+func (v *HelmValues) addImage(node *yaml.Node, keys HelmImage, path string, imageErrors *[]LocatedImageError) {
+	repositoryNode := yamlMappingValue(node, keys.RepositoryKey)
+	location := ImageLocation{
+		Kind:   "image",
+		Path:   path,
+		Line:   repositoryNode.Line,
+		Column: repositoryNode.Column,
+	}
+
+	input := repositoryNode.Value
+	if registry := yamlScalarValue(yamlMappingValue(node, keys.RegistryKey)); registry != "" {
+		input = registry + "/" + input
+	}
+	if tag := yamlScalarValue(yamlMappingValue(node, keys.TagKey)); tag != "" {
+		input += ":" + tag
+	}
+	if digest := yamlScalarValue(yamlMappingValue(node, keys.DigestKey)); digest != "" {
+		input += "@" + digest
+	}
+
+	image, err := ParseDockerImage(input)
+	if err != nil {
+		*imageErrors = append(*imageErrors, LocatedImageError{Input: input, Location: location, Err: err})
+		return
+	}
+
+	keys.Image = image
+	keys.Location = location
+	v.Images = append(v.Images, keys)
+}
+
+// helmImageMapping returns the image keys of the given mapping stored below
+// the given key. ok is false if the mapping has no repository key with a
+// string value, or if it is neither stored below a key containing "image"
+// nor has a tag or digest key.
+func helmImageMapping(node *yaml.Node, key string) (keys HelmImage, ok bool) {
+	if node.Kind != yaml.MappingNode {
+		return HelmImage{}, false
+	}
+
+	find := func(candidates []string) string {
+		for _, candidate := range candidates {
+			if value := yamlMappingValue(node, candidate); value != nil && value.Kind == yaml.ScalarNode {
+				return candidate
+			}
+		}
+		return ""
+	}
+
+	repositoryKeys, tagKeys := helmImageKeys.Repository, helmImageKeys.Tag
+	imageKey := strings.Contains(strings.ToLower(key), "image")
+	if imageKey {
+		repositoryKeys, tagKeys = helmImageKeys.ImageRepository, helmImageKeys.ImageTag
+	}
+
+	keys.RepositoryKey = find(repositoryKeys)
+	if keys.RepositoryKey == "" || yamlMappingValue(node, keys.RepositoryKey).ShortTag() != "!!str" {
+		return HelmImage{}, false
+	}
+	keys.RegistryKey = find(helmImageKeys.Registry)
+	keys.TagKey = find(tagKeys)
+	keys.DigestKey = find(helmImageKeys.Digest)
+
+	if !imageKey && keys.TagKey == "" && keys.DigestKey == "" {
+		return HelmImage{}, false
+	}
+	return keys, true
+}
+
+// yamlNodeAtPath returns the node at a path like "sidecars[0].image", or nil
+// if there is none.
+func yamlNodeAtPath(node *yaml.Node, path string) *yaml.Node {
+	for _, part := range strings.Split(path, ".") {
+		key := part
+		var indexes []int
+		for strings.HasSuffix(key, "]") {
+			i := strings.LastIndex(key, "[")
+			if i < 0 {
+				return nil
+			}
+			var index int
+			if _, err := fmt.Sscanf(key[i:], "[%d]", &index); err != nil {
+				return nil
+			}
+			indexes = append([]int{index}, indexes...)
+			key = key[:i]
+		}
+
+		node = yamlMappingValue(node, key)
+		for _, index := range indexes {
+			if node == nil || node.Kind != yaml.SequenceNode || index < 0 || index >= len(node.Content) {
+				return nil
+			}
+			node = node.Content[index]
+		}
+		if node == nil {
+			return nil
+		}
+	}
+	return node
+}
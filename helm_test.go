@@ -0,0 +1,186 @@
+package generictypes
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/juju/errgo"
+)
+
+const testHelmValues = `# Default values for app.
+image:
+  registry: quay.io
+  repository: giantswarm/app
+  tag: 1.20 # numeric on purpose
+  pullPolicy: IfNotPresent
+
+controller:
+  image:
+    repository: docker.io/library/nginx
+    tag: "1.25"
+    digest: sha256:7d91b69e04a9029b99f3585aaaccae2baa80bcf318f4a5d2165a9898cd2dc0a1
+
+sidecars:
+  - name: proxy
+    image:
+      name: envoyproxy/envoy
+      version: v1.28.0
+
+exporter:
+  imageRegistry: gcr.io
+  repository: giantswarm/exporter
+  tag: latest
+
+chartRepository:
+  repository: https://charts.example.com
+
+broken:
+  image:
+    repository: Invalid Image
+
+dependencies: [{name: redis, version: 17.0.0, repository: "https://charts.bitnami.com/bitnami"}]
+subchart:
+  name: postgresql
+  version: 12.0.0
+`
+
+func TestLoadHelmValues(t *testing.T) {
+	values, imageErrors, err := LoadHelmValues(strings.NewReader(testHelmValues))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if len(imageErrors) != 1 || imageErrors[0].Location.Path != "broken.image" || imageErrors[0].Location.Line != 30 {
+		t.Fatalf("Unexpected image errors %#v", imageErrors)
+	}
+
+	var helmImages = []struct {
+		Path  string
+		Image string
+	}{
+		{"image", "quay.io/giantswarm/app:1.20"},
+		{"controller.image", "docker.io/library/nginx:1.25@" + testDigest},
+		{"sidecars[0].image", "envoyproxy/envoy:v1.28.0"},
+		{"exporter", "gcr.io/giantswarm/exporter:latest"},
+	}
+	if len(values.Images) != len(helmImages) {
+		t.Fatalf("Expected %d images, got %#v", len(helmImages), values.Images)
+	}
+	for i, data := range helmImages {
+		image := values.Images[i]
+		if image.Location.Path != data.Path || image.Image.String() != data.Image {
+			t.Fatalf("Expected %s at %s, got %s at %s", data.Image, data.Path, image.Image.String(), image.Location.Path)
+		}
+	}
+
+	// Chart dependencies have name and version keys, but are no images.
+	for _, path := range []string{"dependencies[0]", "subchart"} {
+		if image, ok := values.Image(path); ok {
+			t.Fatalf("Expected no image at %s, got %#v", path, image)
+		}
+	}
+
+	image, _ := values.Image("image")
+	if image.RegistryKey != "registry" || image.RepositoryKey != "repository" || image.TagKey != "tag" || image.DigestKey != "" {
+		t.Fatalf("Unexpected keys %#v", image)
+	}
+	if image.Location.Line != 4 {
+		t.Fatalf("Expected image at line 4, got %d", image.Location.Line)
+	}
+}
+
+func TestHelmValues_SetImage(t *testing.T) {
+	values, _, err := LoadHelmValues(strings.NewReader(testHelmValues))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	var changes = []struct {
+		Path  string
+		Image string
+	}{
+		{"image", "quay.io/giantswarm/app:1.21@" + testDigest},
+		{"controller.image", "nginx:1.26"},
+		{"sidecars[0].image", "ghcr.io/envoyproxy/envoy:v1.29.0"},
+		{"exporter", "giantswarm/exporter:1.0.0"},
+	}
+	for _, data := range changes {
+		if err := values.SetImage(data.Path, MustParseDockerImage(data.Image)); err != nil {
+			t.Fatalf("Failed to set image at %s: %v", data.Path, err)
+		}
+		image, ok := values.Image(data.Path)
+		if !ok || image.Image.String() != data.Image {
+			t.Fatalf("Expected image %s at %s after update, got %#v", data.Image, data.Path, image)
+		}
+	}
+
+	expected := strings.NewReplacer(
+		"  registry: quay.io\n", "  digest: "+testDigest+"\n  registry: quay.io\n",
+		"tag: 1.20 # numeric", `tag: "1.21" # numeric`,
+		"repository: docker.io/library/nginx", "repository: nginx",
+		`tag: "1.25"`, `tag: "1.26"`,
+		"    digest: "+testDigest, `    digest: ""`,
+		"name: envoyproxy/envoy", "name: ghcr.io/envoyproxy/envoy",
+		"version: v1.28.0", "version: v1.29.0",
+		"imageRegistry: gcr.io", `imageRegistry: ""`,
+		"tag: latest", "tag: 1.0.0",
+	).Replace(testHelmValues)
+
+	if string(values.Bytes()) != expected {
+		t.Fatalf("Expected values\n%s\ngot\n%s", expected, string(values.Bytes()))
+	}
+
+	if err := values.SetImage("chartRepository", MustParseDockerImage("redis")); err == nil {
+		t.Fatalf("Expected error for path without image")
+	}
+	if err := values.SetImage("unknown.image", MustParseDockerImage("redis")); !errors.Is(err, ErrInvalidHelmValues) || errgo.Cause(err) != ErrInvalidHelmValues {
+		t.Fatalf("Expected ErrInvalidHelmValues for unknown path, got %v", err)
+	}
+	if err := values.SetImage("subchart", MustParseDockerImage("redis")); !errors.Is(err, ErrInvalidHelmValues) {
+		t.Fatalf("Expected ErrInvalidHelmValues for chart without image, got %v", err)
+	}
+}
+
+func TestHelmValues_SetImageFlowMapping(t *testing.T) {
+	source := "image: {repository: nginx, tag: \"1.0\"}\nsidecar:\n  image: &proxy {name: envoy, version: v1}\n"
+	values, _, err := LoadHelmValues(strings.NewReader(source))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := values.SetImage("image", MustParseDockerImage("nginx:1.1")); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := values.SetImage("sidecar.image", MustParseDockerImage("envoy:v2")); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	expected := "image: {repository: nginx, tag: \"1.1\"}\nsidecar:\n  image: &proxy {name: envoy, version: v2}\n"
+	if string(values.Bytes()) != expected {
+		t.Fatalf("Expected\n%s\ngot\n%s", expected, values.Bytes())
+	}
+}
+
+var yamlPaths = []struct {
+	Path          string
+	ExpectedValue string
+}{
+	{"image.tag", "1.20"},
+	{"sidecars[0].name", "proxy"},
+	{"sidecars[0].image.version", "v1.28.0"},
+	{"sidecars[1].name", ""},
+	{"sidecars.name", ""},
+	{"image.missing", ""},
+}
+
+func TestYAMLNodeAtPath(t *testing.T) {
+	values, _, err := LoadHelmValues(strings.NewReader(testHelmValues))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	for _, data := range yamlPaths {
+		if value := yamlScalarValue(yamlNodeAtPath(values.root, data.Path)); value != data.ExpectedValue {
+			t.Fatalf("Expected '%s' at %s, got '%s'", data.ExpectedValue, data.Path, value)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package generictypes
 
 import (
 	"github.com/juju/errgo"
+	"gopkg.in/yaml.v3"
 
 	"encoding/json"
 	"regexp"
@@ -13,6 +14,9 @@ var (
 	PatternNamespace = regexp.MustCompile(`^([a-z0-9_]{4,30})$`)
 	PatternImage     = regexp.MustCompile(`^([a-z0-9-_.]+)$`)
 	PatternVersion   = regexp.MustCompile("^[a-zA-Z0-9-\\._]+$")
+
+	// https://github.com/opencontainers/image-spec/blob/v1.0.1/descriptor.md#digests
+	PatternDigest = regexp.MustCompile(`^[a-z0-9]+([+._-][a-z0-9]+)*:[a-zA-Z0-9=_-]+$`)
 )
 
 var (
@@ -20,7 +24,7 @@ var (
 	// The format given here is the one docker documents. But the repository also consist of a
 	// namespace which is more or less always there. Since our business logic requires some checks based on the
 	// namespace, we parse it explicitly.
-	ErrInvalidFormat = errgo.New("Not a valid docker image. Format: [<registry>/]<repository>[:<version>]")
+	ErrInvalidFormat = errgo.New("Not a valid docker image. Format: [<registry>/]<repository>[:<version>][@<digest>]")
 )
 
 func MustParseDockerImage(image string) DockerImage {
@@ -42,6 +46,7 @@ type DockerImage struct {
 	Namespace  string // The namespace
 	Repository string // The repository name
 	Version    string // The version part
+	Digest     string // The content digest, e.g. "sha256:..."
 }
 
 func (img DockerImage) MarshalJSON() ([]byte, error) {
@@ -54,7 +59,40 @@ func (img *DockerImage) UnmarshalJSON(data []byte) error {
 	if err := json.Unmarshal(data, &input); err != nil {
 		return err
 	}
-	return img.parse(input)
+	return img.UnmarshalText([]byte(input))
+}
+
+// MarshalText implements encoding.TextMarshaler, so images can be used e.g. as
+// JSON map keys.
+func (img DockerImage) MarshalText() ([]byte, error) {
+	return []byte(img.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler with the same validation
+// as UnmarshalJSON.
+func (img *DockerImage) UnmarshalText(data []byte) error {
+	var parsed DockerImage
+	if err := parsed.parse(string(data)); err != nil {
+		return err
+	}
+	*img = parsed
+	return nil
+}
+
+// MarshalYAML implements yaml.Marshaler.
+func (img DockerImage) MarshalYAML() (interface{}, error) {
+	return img.String(), nil
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler. Errors contain the line and
+// column of the image.
+func (img *DockerImage) UnmarshalYAML(node *yaml.Node) error {
+	parsed, err := parseYAMLImage(node)
+	if err != nil {
+		return yamlNodeError(node, err)
+	}
+	*img = parsed
+	return nil
 }
 
 // DefaultLatestVersion returns an image that has Version set to "latest" when it
@@ -82,17 +120,90 @@ func (img DockerImage) Validate() error {
 	return tmp.parse(img.String())
 }
 
+// WithRegistry returns a copy of the image with Registry set to the given
+// registry. An error is returned if the resulting image is not valid.
+func (img DockerImage) WithRegistry(registry string) (DockerImage, error) {
+	img.Registry = registry
+	return img.validated()
+}
+
+// WithNamespace returns a copy of the image with Namespace set to the given
+// namespace. An error is returned if the resulting image is not valid.
+func (img DockerImage) WithNamespace(namespace string) (DockerImage, error) {
+	img.Namespace = namespace
+	return img.validated()
+}
+
+// WithRepository returns a copy of the image with Repository set to the given
+// repository. An error is returned if the resulting image is not valid.
+func (img DockerImage) WithRepository(repository string) (DockerImage, error) {
+	img.Repository = repository
+	return img.validated()
+}
+
+// WithTag returns a copy of the image with Version set to the given tag.
+// An error is returned if the resulting image is not valid.
+func (img DockerImage) WithTag(tag string) (DockerImage, error) {
+	img.Version = tag
+	return img.validated()
+}
+
+// WithDigest returns a copy of the image with Digest set to the given digest.
+// An error is returned if the resulting image is not valid.
+func (img DockerImage) WithDigest(digest string) (DockerImage, error) {
+	img.Digest = digest
+	return img.validated()
+}
+
+// WithoutTag returns a copy of the image with Version unset.
+// An error is returned if the resulting image is not valid.
+func (img DockerImage) WithoutTag() (DockerImage, error) {
+	img.Version = ""
+	return img.validated()
+}
+
+// validated returns the image if it is valid and parses back into exactly the
+// same fields. Otherwise e.g. a registry without a dot would silently turn
+// into a namespace once the image is serialized.
+func (img DockerImage) validated() (DockerImage, error) {
+	parsed, err := ParseDockerImage(img.String())
+	if err != nil {
+		return DockerImage{}, err
+	}
+	if parsed != img {
+		return DockerImage{}, newImageError(img.String(), "Ambiguous image %#v", img.String())
+	}
+	return img, nil
+}
+
 func (img *DockerImage) parse(input string) error {
+	original := input
 	if len(input) == 0 {
-		return errgo.Notef(ErrInvalidFormat, "Zero length")
+		return newImageError(original, "Zero length")
 	}
 	if strings.Contains(input, " ") {
-		return errgo.Notef(ErrInvalidFormat, "No whitespaces allowed")
+		return newImageError(original, "No whitespaces allowed")
+	}
+
+	// Split off the digest first, since it contains a colon itself.
+	splitByDigestSeparator := strings.Split(input, "@")
+	switch len(splitByDigestSeparator) {
+	case 2:
+		input = splitByDigestSeparator[0]
+		img.Digest = splitByDigestSeparator[1]
+
+		if !isDigest(img.Digest) {
+			return newImageError(original, "Invalid digest %#v", img.Digest)
+		}
+	case 1:
+		img.Digest = ""
+	default:
+		return newImageError(original, "Too many digest separators")
 	}
 
 	splitByPath := strings.Split(input, "/")
 	if len(splitByPath) > 3 {
-		return errgo.Notef(ErrInvalidFormat, "Too many path elements")
+		return newImageError(original, "Too many path elements")
 	}
 
 	if containsRegistry(splitByPath) {
@@ -108,10 +219,10 @@ func (img *DockerImage) parse(input string) error {
 		img.Repository = splitByPath[1]
 
 		if !isNamespace(img.Namespace) {
-			return errgo.Notef(ErrInvalidFormat, "Invalid namespace part: "+img.Namespace)
+			return newImageError(original, "Invalid namespace part: %s", img.Namespace)
 		}
 	default:
-		return errgo.Notef(ErrInvalidFormat, "Invalid format")
+		return newImageError(original, "Invalid format")
 	}
 
 	// Now split img.Repository into img.Repository and img.Version
@@ -123,7 +234,7 @@ func (img *DockerImage) parse(input string) error {
 		img.Version = splitByVersionSeparator[1]
 
 		if !isVersion(img.Version) {
-			return errgo.Notef(ErrInvalidFormat, "Invalid version %#v", img.Version)
+			return newImageError(original, "Invalid version %#v", img.Version)
 		}
 	case 1:
 		img.Repository = splitByVersionSeparator[0]
@@ -133,11 +244,11 @@ func (img *DockerImage) parse(input string) error {
 		// is none given.
 		img.Version = ""
 	default:
-		return errgo.Notef(ErrInvalidFormat, "Too many double colons")
+		return newImageError(original, "Too many double colons")
 	}
 
 	if !isImage(img.Repository) {
-		return errgo.Notef(ErrInvalidFormat, "Invalid image part %#v", img.Repository)
+		return newImageError(original, "Invalid image part %#v", img.Repository)
 	}
 	return nil
 }
@@ -159,7 +270,7 @@ func (img DockerImage) UnversionedString() string {
 	return imageString
 }
 
-// Returns all image information combined: <registry>/<namespace>/<repository>:<version>
+// Returns all image information combined: <registry>/<namespace>/<repository>:<version>@<digest>
 func (img DockerImage) String() string {
 	var imageString string
 
@@ -169,6 +280,10 @@ func (img DockerImage) String() string {
 		imageString += ":" + img.Version
 	}
 
+	if img.Digest != "" {
+		imageString += "@" + img.Digest
+	}
+
 	return imageString
 }
 
@@ -203,3 +318,7 @@ func isImage(input string) bool {
 func isVersion(input string) bool {
 	return PatternVersion.MatchString(input)
 }
+
+func isDigest(input string) bool {
+	return PatternDigest.MatchString(input)
+}
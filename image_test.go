@@ -2,7 +2,10 @@ package generictypes
 
 import (
 	"encoding/json"
+	"strings"
 	"testing"
+
+	"gopkg.in/yaml.v3"
 )
 
 func TestMarshal(t *testing.T) {
@@ -351,3 +354,213 @@ func TestNamespaceDefaulting(t *testing.T) {
 		t.Fatalf("Expected image version to be unchanged, got '%s'", libraryImage.Namespace)
 	}
 }
+
+const testDigest = "sha256:7d91b69e04a9029b99f3585aaaccae2baa80bcf318f4a5d2165a9898cd2dc0a1"
+
+var digestParsings = []struct {
+	Input string
+
+	ExpectedRepository string
+	ExpectedVersion    string
+	ExpectedDigest     string
+}{
+	{"redis@" + testDigest, "redis", "", testDigest},
+	{"redis:3.0@" + testDigest, "redis", "3.0", testDigest},
+	{"192.168.59.103:5000/sharethemeal/payment:1.0@" + testDigest, "payment", "1.0", testDigest},
+}
+
+func TestDigestParsing(t *testing.T) {
+	for _, data := range digestParsings {
+		image, err := ParseDockerImage(data.Input)
+		if err != nil {
+			t.Fatalf("Failed to parse docker image %#v: %v", data.Input, err)
+		}
+
+		if image.Repository != data.ExpectedRepository {
+			t.Fatalf("Unexpected repository: '%s' but got '%s'", data.ExpectedRepository, image.Repository)
+		}
+		if image.Version != data.ExpectedVersion {
+			t.Fatalf("Unexpected version: '%s' but got '%s'", data.ExpectedVersion, image.Version)
+		}
+		if image.Digest != data.ExpectedDigest {
+			t.Fatalf("Unexpected digest: '%s' but got '%s'", data.ExpectedDigest, image.Digest)
+		}
+		if image.String() != data.Input {
+			t.Fatalf("Unexpected string conversion output: '%s' but got '%s'", data.Input, image.String())
+		}
+	}
+}
+
+var invalidDigestImages = []struct {
+	Input string
+}{
+	{"redis@"},
+	{"redis@sha256"},
+	{"redis@sha256:"},
+	{"redis@" + testDigest + "@" + testDigest},
+	{"redis@SHA256:abc"},
+}
+
+func TestDigestParsingErrors(t *testing.T) {
+	for _, data := range invalidDigestImages {
+		image, err := ParseDockerImage(data.Input)
+		if err == nil {
+			t.Fatalf("Expected error for input: %v\nBut got: %#v", data.Input, image)
+		}
+	}
+}
+
+func TestWithMethods(t *testing.T) {
+	img := MustParseDockerImage("zeisss/static-website:1.0")
+
+	changed, err := img.WithRegistry("registry.giantswarm.io")
+	if err != nil {
+		t.Fatalf("WithRegistry failed: %v", err)
+	}
+	changed, err = changed.WithNamespace("denderello")
+	if err != nil {
+		t.Fatalf("WithNamespace failed: %v", err)
+	}
+	changed, err = changed.WithRepository("website")
+	if err != nil {
+		t.Fatalf("WithRepository failed: %v", err)
+	}
+	changed, err = changed.WithTag("2.0")
+	if err != nil {
+		t.Fatalf("WithTag failed: %v", err)
+	}
+	changed, err = changed.WithDigest(testDigest)
+	if err != nil {
+		t.Fatalf("WithDigest failed: %v", err)
+	}
+
+	expectedString := "registry.giantswarm.io/denderello/website:2.0@" + testDigest
+	if changed.String() != expectedString {
+		t.Fatalf("Expected '%s', got '%s'", expectedString, changed.String())
+	}
+
+	changed, err = changed.WithoutTag()
+	if err != nil {
+		t.Fatalf("WithoutTag failed: %v", err)
+	}
+
+	expectedString = "registry.giantswarm.io/denderello/website@" + testDigest
+	if changed.String() != expectedString {
+		t.Fatalf("Expected '%s', got '%s'", expectedString, changed.String())
+	}
+
+	if img.String() != "zeisss/static-website:1.0" {
+		t.Fatalf("Expected original image to be unchanged, got '%s'", img.String())
+	}
+}
+
+var invalidWithChanges = []struct {
+	Name   string
+	Change func(DockerImage) (DockerImage, error)
+}{
+	{"registry without dot", func(img DockerImage) (DockerImage, error) { return img.WithRegistry("localhost") }},
+	{"registry with path", func(img DockerImage) (DockerImage, error) { return img.WithRegistry("quay.io/foo") }},
+	{"short namespace", func(img DockerImage) (DockerImage, error) { return img.WithNamespace("foo") }},
+	{"empty repository", func(img DockerImage) (DockerImage, error) { return img.WithRepository("") }},
+	{"uppercase repository", func(img DockerImage) (DockerImage, error) { return img.WithRepository("Redis") }},
+	{"tag with colon", func(img DockerImage) (DockerImage, error) { return img.WithTag("1:2") }},
+	{"tag with whitespace", func(img DockerImage) (DockerImage, error) { return img.WithTag("1 2") }},
+	{"invalid digest", func(img DockerImage) (DockerImage, error) { return img.WithDigest("sha256") }},
+}
+
+func TestWithMethodsErrors(t *testing.T) {
+	img := MustParseDockerImage("redis:3.0")
+
+	for _, data := range invalidWithChanges {
+		changed, err := data.Change(img)
+		if err == nil {
+			t.Fatalf("Expected error for %s\nBut got: %#v", data.Name, changed)
+		}
+	}
+}
+
+func TestTextMarshalling(t *testing.T) {
+	replicas := map[DockerImage]int{
+		MustParseDockerImage("redis:3.0"):                            1,
+		MustParseDockerImage("quay.io/giantswarm/app@" + testDigest): 2,
+	}
+
+	data, err := json.Marshal(replicas)
+	if err != nil {
+		t.Fatalf("Failed to marshal images: %v", err)
+	}
+	expected := `{"quay.io/giantswarm/app@` + testDigest + `":2,"redis:3.0":1}`
+	if string(data) != expected {
+		t.Fatalf("Expected %s, got %s", expected, string(data))
+	}
+
+	var decoded map[DockerImage]int
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Failed to unmarshal images: %v", err)
+	}
+	if decoded[MustParseDockerImage("redis:3.0")] != 1 {
+		t.Fatalf("Unexpected images %v", decoded)
+	}
+
+	if err := json.Unmarshal([]byte(`{"foo/image":1}`), &decoded); err == nil {
+		t.Fatalf("Expected error for invalid image key")
+	}
+}
+
+func TestUnmarshalTextResetsFields(t *testing.T) {
+	image := MustParseDockerImage("registry.giantswarm.io/denderello/static-website:1.0")
+
+	if err := image.UnmarshalText([]byte("redis")); err != nil {
+		t.Fatalf("Failed to unmarshal image: %v", err)
+	}
+	if image.String() != "redis" {
+		t.Fatalf("Expected 'redis', got '%s'", image.String())
+	}
+}
+
+func TestYAMLMarshalling(t *testing.T) {
+	input := "images:\n    - redis:3.0\n    - quay.io/giantswarm/app@" + testDigest + "\n"
+
+	var config struct {
+		Images []DockerImage `yaml:"images"`
+	}
+	if err := yaml.Unmarshal([]byte(input), &config); err != nil {
+		t.Fatalf("Failed to unmarshal images: %v", err)
+	}
+	if len(config.Images) != 2 || config.Images[1].Digest != testDigest {
+		t.Fatalf("Unexpected images %v", config.Images)
+	}
+
+	output, err := yaml.Marshal(config)
+	if err != nil {
+		t.Fatalf("Failed to marshal images: %v", err)
+	}
+	if string(output) != input {
+		t.Fatalf("Expected:\n%s\ngot:\n%s", input, string(output))
+	}
+}
+
+var invalidYAMLImages = []struct {
+	Input  string
+	Prefix string
+}{
+	{"image: foo/image\n", "Line 1, column 8: "},
+	{"name: test\nimage: 42\n", "Line 2, column 8: "},
+	{"image:\n  name: redis\n", "Line 2, column 3: "},
+}
+
+func TestYAMLUnmarshallingErrors(t *testing.T) {
+	for _, data := range invalidYAMLImages {
+		var config struct {
+			Name  string      `yaml:"name"`
+			Image DockerImage `yaml:"image"`
+		}
+		err := yaml.Unmarshal([]byte(data.Input), &config)
+		if err == nil {
+			t.Fatalf("Expected error for input: %v\nBut got: %v", data.Input, config.Image)
+		}
+		if !strings.Contains(err.Error(), data.Prefix) {
+			t.Fatalf("Expected error to contain '%s', got '%v'", data.Prefix, err)
+		}
+	}
+}
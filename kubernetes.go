@@ -0,0 +1,165 @@
+package generictypes
+
+import (
+	"gopkg.in/yaml.v3"
+
+	"fmt"
+	"io"
+	"strings"
+)
+
+// podSpecPaths maps the workload kinds we scan to the path of their pod spec.
+var podSpecPaths = map[string][]string{
+	"Pod":         {"spec"},
+	"Deployment":  {"spec", "template", "spec"},
+	"StatefulSet": {"spec", "template", "spec"},
+	"DaemonSet":   {"spec", "template", "spec"},
+	"ReplicaSet":  {"spec", "template", "spec"},
+	"Job":         {"spec", "template", "spec"},
+	"CronJob":     {"spec", "jobTemplate", "spec", "template", "spec"},
+}
+
+// podSpecContainerFields are the pod spec fields holding lists of containers.
+var podSpecContainerFields = []string{"initContainers", "containers", "ephemeralContainers"}
+
+// ScanKubernetesImages reads a stream of YAML or JSON documents and returns
+// every image used by containers of Pods, Deployments, StatefulSets,
+// DaemonSets, Jobs, CronJobs and ReplicaSets, also inside v1 Lists. Images
+// that cannot be parsed are returned as errors instead of aborting the scan.
+// The returned error is only set if the stream itself cannot be read, the
+// images of the documents before the broken one are returned along with it.
+func ScanKubernetesImages(r io.Reader) ([]LocatedImage, []LocatedImageError, error) {
+	var (
+		images      []LocatedImage
+		imageErrors []LocatedImageError
+	)
+
+	decoder := yaml.NewDecoder(r)
+	for document := 0; ; document++ {
+		var node yaml.Node
+		if err := decoder.Decode(&node); err == io.EOF {
+			break
+		} else if err != nil {
+			return images, imageErrors, notef(err, "Invalid document %d", document)
+		}
+		if len(node.Content) == 0 {
+			continue
+		}
+
+		scanKubernetesObject(node.Content[0], ImageLocation{Document: document}, "", &images, &imageErrors)
+	}
+
+	return images, imageErrors, nil
+}
+
+// scanKubernetesObject collects the images of the given object, descending
+// into the items of lists.
+func scanKubernetesObject(object *yaml.Node, location ImageLocation, path string, images *[]LocatedImage, imageErrors *[]LocatedImageError) {
+	if object.Kind != yaml.MappingNode {
+		return
+	}
+
+	kind := yamlScalarValue(yamlMappingValue(object, "kind"))
+	if strings.HasSuffix(kind, "List") {
+		items := yamlMappingValue(object, "items")
+		if items == nil || items.Kind != yaml.SequenceNode {
+			return
+		}
+		for i, item := range items.Content {
+			scanKubernetesObject(item, location, fmt.Sprintf("%sitems[%d].", path, i), images, imageErrors)
+		}
+		return
+	}
+
+	specPath, ok := podSpecPaths[kind]
+	if !ok {
+		return
+	}
+
+	location.Kind = kind
+	location.Name = yamlScalarValue(yamlMappingValue(yamlMappingValue(object, "metadata"), "name"))
+
+	podSpec := object
+	for _, key := range specPath {
+		podSpec = yamlMappingValue(podSpec, key)
+	}
+	if podSpec == nil {
+		return
+	}
+	podSpecPath := path + strings.Join(specPath, ".")
+
+	for _, field := range podSpecContainerFields {
+		containers := yamlMappingValue(podSpec, field)
+		if containers == nil || containers.Kind != yaml.SequenceNode {
+			continue
+		}
+
+		for i, container := range containers.Content {
+			imageNode := yamlMappingValue(container, "image")
+			if imageNode == nil {
+				continue
+			}
+			// Aliases are located where they are used, not at their anchor.
+			entry := yamlMappingEntry(container, "image")
+
+			imageLocation := location
+			imageLocation.Path = fmt.Sprintf("%s.%s[%d].image", podSpecPath, field, i)
+			imageLocation.Line = entry.Line
+			imageLocation.Column = entry.Column
+
+			image, err := parseYAMLImage(imageNode)
+			if err != nil {
+				*imageErrors = append(*imageErrors, LocatedImageError{Input: imageNode.Value, Location: imageLocation, Err: err})
+				continue
+			}
+			*images = append(*images, LocatedImage{Image: image, Location: imageLocation})
+		}
+	}
+}
+
+// parseYAMLImage parses the image in the given scalar string node.
+func parseYAMLImage(node *yaml.Node) (DockerImage, error) {
+	if node.Kind != yaml.ScalarNode || node.ShortTag() != "!!str" {
+		return DockerImage{}, newImageError(node.Value, "Not a string")
+	}
+	return ParseDockerImage(node.Value)
+}
+
+// yamlMappingValue returns the value of the given key in a mapping node, or
+// nil if the node is not a mapping or the key does not exist. Aliases are
+// resolved to the node they refer to.
+func yamlMappingValue(node *yaml.Node, key string) *yaml.Node {
+	value := yamlMappingEntry(node, key)
+	if value != nil && value.Kind == yaml.AliasNode {
+		return value.Alias
+	}
+	return value
+}
+
+// yamlMappingEntry returns the value of the given key in a mapping node like
+// yamlMappingValue, but without resolving aliases.
+func yamlMappingEntry(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// yamlScalarValue returns the value of a scalar node, or "" for all other nodes.
+func yamlScalarValue(node *yaml.Node) string {
+	if node == nil || node.Kind != yaml.ScalarNode {
+		return ""
+	}
+	return node.Value
+}
+
+// yamlNodeError prefixes err with the line and column of the given node,
+// keeping its cause.
+func yamlNodeError(node *yaml.Node, err error) error {
+	return &yamlError{Line: node.Line, Column: node.Column, Err: err}
+}
@@ -0,0 +1,129 @@
+package generictypes
+
+import (
+	"strings"
+	"testing"
+)
+
+const testKubernetesManifests = `---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: api
+spec:
+  template:
+    spec:
+      initContainers:
+      - name: migrate
+        image: quay.io/giantswarm/api-migrations:1.0.0
+      containers:
+      - name: api
+        image: &api quay.io/giantswarm/api:1.0.0
+      - name: sidecar
+        image: *api
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+data:
+  image: not-an-image-field
+---
+apiVersion: batch/v1
+kind: CronJob
+metadata:
+  name: cleanup
+spec:
+  jobTemplate:
+    spec:
+      template:
+        spec:
+          containers:
+          - name: cleanup
+            image: Invalid Image
+          - name: cleanup-2
+            image: 42
+---
+{
+  "apiVersion": "v1",
+  "kind": "List",
+  "items": [
+    {
+      "apiVersion": "v1",
+      "kind": "Pod",
+      "metadata": {"name": "debug"},
+      "spec": {
+        "containers": [{"name": "app", "image": "alpine:3.18"}],
+        "ephemeralContainers": [{"name": "debugger", "image": "busybox"}]
+      }
+    }
+  ]
+}
+`
+
+func TestScanKubernetesImages(t *testing.T) {
+	images, imageErrors, err := ScanKubernetesImages(strings.NewReader(testKubernetesManifests))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	var expectedImages = []struct {
+		Image    string
+		Document int
+		Kind     string
+		Name     string
+		Path     string
+		Line     int
+	}{
+		{"quay.io/giantswarm/api-migrations:1.0.0", 0, "Deployment", "api", "spec.template.spec.initContainers[0].image", 11},
+		{"quay.io/giantswarm/api:1.0.0", 0, "Deployment", "api", "spec.template.spec.containers[0].image", 14},
+		{"quay.io/giantswarm/api:1.0.0", 0, "Deployment", "api", "spec.template.spec.containers[1].image", 16},
+		{"alpine:3.18", 3, "Pod", "debug", "items[0].spec.containers[0].image", 0},
+		{"busybox", 3, "Pod", "debug", "items[0].spec.ephemeralContainers[0].image", 0},
+	}
+
+	if len(images) != len(expectedImages) {
+		t.Fatalf("Expected %d images, got %#v", len(expectedImages), images)
+	}
+	for i, expected := range expectedImages {
+		image := images[i]
+		if image.Image.String() != expected.Image {
+			t.Fatalf("Expected image '%s', got '%s'", expected.Image, image.Image.String())
+		}
+		if image.Location.Document != expected.Document || image.Location.Kind != expected.Kind || image.Location.Name != expected.Name || image.Location.Path != expected.Path {
+			t.Fatalf("Unexpected location for %s: %#v", expected.Image, image.Location)
+		}
+		if expected.Line != 0 && image.Location.Line != expected.Line {
+			t.Fatalf("Expected %s at line %d, got %d", expected.Image, expected.Line, image.Location.Line)
+		}
+	}
+
+	if len(imageErrors) != 2 {
+		t.Fatalf("Expected 2 image errors, got %#v", imageErrors)
+	}
+	if imageErrors[0].Input != "Invalid Image" || imageErrors[0].Location.Path != "spec.jobTemplate.spec.template.spec.containers[0].image" || imageErrors[0].Location.Line != 36 {
+		t.Fatalf("Unexpected image error %#v", imageErrors[0])
+	}
+	if !strings.Contains(imageErrors[0].Error(), `CronJob "cleanup"`) {
+		t.Fatalf("Expected error message to contain the object, got '%s'", imageErrors[0].Error())
+	}
+	if imageErrors[1].Input != "42" {
+		t.Fatalf("Unexpected image error %#v", imageErrors[1])
+	}
+}
+
+func TestScanKubernetesImagesInvalidYAML(t *testing.T) {
+	_, _, err := ScanKubernetesImages(strings.NewReader("kind: Pod\n---\nkind: [Pod\n"))
+	if err == nil {
+		t.Fatalf("Expected error for invalid YAML")
+	}
+
+	// Images found before a broken document are returned with the error.
+	images, imageErrors, err := ScanKubernetesImages(strings.NewReader(testKubernetesManifests + "---\nkind: [Pod\n"))
+	if err == nil || !strings.Contains(err.Error(), "Invalid document 4") {
+		t.Fatalf("Expected error for document 4, got %v", err)
+	}
+	if len(images) != 5 || len(imageErrors) != 2 {
+		t.Fatalf("Expected the images of the valid documents, got %#v, %#v", images, imageErrors)
+	}
+}
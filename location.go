@@ -0,0 +1,59 @@
+package generictypes
+
+import (
+	"fmt"
+)
+
+// ImageLocation describes where an image reference was found in a file.
+type ImageLocation struct {
+	// Document is the index of the document in a multi-document stream.
+	Document int
+
+	// Kind and Name identify the object containing the reference, e.g. a
+	// Kubernetes Deployment or a Compose service.
+	Kind string
+	Name string
+
+	// Path is the field path of the reference, e.g.
+	// "spec.template.spec.containers[0].image".
+	Path string
+
+	// Line and Column are 1-based, 0 means unknown.
+	Line   int
+	Column int
+}
+
+func (l ImageLocation) String() string {
+	s := fmt.Sprintf("document %d", l.Document)
+	if l.Line > 0 {
+		s += fmt.Sprintf(", line %d column %d", l.Line, l.Column)
+	}
+	if l.Kind != "" || l.Name != "" {
+		s += fmt.Sprintf(", %s %#v", l.Kind, l.Name)
+	}
+	if l.Path != "" {
+		s += ", " + l.Path
+	}
+	return s
+}
+
+// LocatedImage is an image together with the location it was found at.
+type LocatedImage struct {
+	Image    DockerImage
+	Location ImageLocation
+}
+
+// LocatedImageError reports an image reference that could not be parsed.
+type LocatedImageError struct {
+	Input    string
+	Location ImageLocation
+	Err      error
+}
+
+func (e LocatedImageError) Error() string {
+	return fmt.Sprintf("%s: invalid image %#v: %v", e.Location, e.Input, e.Err)
+}
+
+func (e LocatedImageError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,271 @@
+package generictypes
+
+import (
+	"github.com/juju/errgo"
+
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"hash"
+	"strings"
+)
+
+// Media types of manifests, configs and layers, see
+// https://github.com/opencontainers/image-spec/blob/v1.1.0/media-types.md and
+// https://docs.docker.com/registry/spec/manifest-v2-2/.
+const (
+	MediaTypeOCIManifest  = "application/vnd.oci.image.manifest.v1+json"
+	MediaTypeOCIIndex     = "application/vnd.oci.image.index.v1+json"
+	MediaTypeOCIConfig    = "application/vnd.oci.image.config.v1+json"
+	MediaTypeOCILayer     = "application/vnd.oci.image.layer.v1.tar"
+	MediaTypeOCILayerGzip = "application/vnd.oci.image.layer.v1.tar+gzip"
+	MediaTypeOCILayerZstd = "application/vnd.oci.image.layer.v1.tar+zstd"
+	MediaTypeOCIEmptyJSON = "application/vnd.oci.empty.v1+json"
+
+	MediaTypeDockerManifest     = "application/vnd.docker.distribution.manifest.v2+json"
+	MediaTypeDockerManifestList = "application/vnd.docker.distribution.manifest.list.v2+json"
+	MediaTypeDockerConfig       = "application/vnd.docker.container.image.v1+json"
+	MediaTypeDockerLayer        = "application/vnd.docker.image.rootfs.diff.tar.gzip"
+)
+
+var (
+	ErrInvalidManifest    = errgo.New("Invalid manifest")
+	ErrDigestMismatch     = errgo.New("Digest mismatch")
+	ErrUnsupportedDigest  = errgo.New("Unsupported digest algorithm")
+	ErrNoMatchingManifest = errgo.New("No manifest matches the platform")
+)
+
+// Descriptor describes content addressed by its digest, see
+// https://github.com/opencontainers/image-spec/blob/v1.1.0/descriptor.md.
+type Descriptor struct {
+	MediaType    string              `json:"mediaType"`
+	Digest       string              `json:"digest"`
+	Size         int64               `json:"size"`
+	URLs         []string            `json:"urls,omitempty"`
+	Annotations  map[string]string   `json:"annotations,omitempty"`
+	Data         []byte              `json:"data,omitempty"`
+	Platform     *DescriptorPlatform `json:"platform,omitempty"`
+	ArtifactType string              `json:"artifactType,omitempty"`
+}
+
+// DescriptorPlatform is the platform of a manifest referenced by an image
+// index or manifest list.
+type DescriptorPlatform struct {
+	Architecture string   `json:"architecture"`
+	OS           string   `json:"os"`
+	OSVersion    string   `json:"os.version,omitempty"`
+	OSFeatures   []string `json:"os.features,omitempty"`
+	Variant      string   `json:"variant,omitempty"`
+	Features     []string `json:"features,omitempty"`
+}
+
+// Platform returns the descriptor platform as a Platform.
+func (d DescriptorPlatform) Platform() Platform {
+	return Platform{
+		OS:           d.OS,
+		Architecture: d.Architecture,
+		Variant:      d.Variant,
+		OSVersion:    d.OSVersion,
+	}
+}
+
+// DescriptorPlatform returns the platform in the form used by descriptors.
+func (p Platform) DescriptorPlatform() DescriptorPlatform {
+	return DescriptorPlatform{
+		Architecture: p.Architecture,
+		OS:           p.OS,
+		OSVersion:    p.OSVersion,
+		Variant:      p.Variant,
+	}
+}
+
+// Validate checks that the descriptor has a media type, a valid digest and a
+// non-negative size. Returns nil if valid, or an error if not valid.
+func (d Descriptor) Validate() error {
+	if d.MediaType == "" {
+		return notef(ErrInvalidManifest, "Descriptor without media type")
+	}
+	if !isDigest(d.Digest) {
+		return notef(ErrInvalidManifest, "Invalid digest %#v", d.Digest)
+	}
+	if d.Size < 0 {
+		return notef(ErrInvalidManifest, "Negative size %d", d.Size)
+	}
+	return nil
+}
+
+// Verify checks that the given content matches the size and digest of the
+// descriptor. sha256 and sha512 digests are supported.
+func (d Descriptor) Verify(content []byte) error {
+	if int64(len(content)) != d.Size {
+		return notef(ErrDigestMismatch, "Expected size %d, got %d", d.Size, len(content))
+	}
+
+	var h hash.Hash
+	algorithm := strings.SplitN(d.Digest, ":", 2)[0]
+	switch algorithm {
+	case "sha256":
+		h = sha256.New()
+	case "sha512":
+		h = sha512.New()
+	default:
+		return notef(ErrUnsupportedDigest, "%#v", algorithm)
+	}
+	h.Write(content)
+
+	if digest := algorithm + ":" + hex.EncodeToString(h.Sum(nil)); digest != d.Digest {
+		return notef(ErrDigestMismatch, "Expected %s, got %s", d.Digest, digest)
+	}
+	return nil
+}
+
+// Reference returns the image referring to this descriptor's content in the
+// given repository, e.g. "quay.io/giantswarm/app@sha256:...".
+func (d Descriptor) Reference(repository DockerImage) (DockerImage, error) {
+	img, err := repository.WithoutTag()
+	if err != nil {
+		return DockerImage{}, err
+	}
+	return img.WithDigest(d.Digest)
+}
+
+// ImageManifest is an OCI image manifest or a Docker v2 schema 2 manifest,
+// which share the same structure, see
+// https://github.com/opencontainers/image-spec/blob/v1.1.0/manifest.md.
+type ImageManifest struct {
+	SchemaVersion int               `json:"schemaVersion"`
+	MediaType     string            `json:"mediaType,omitempty"`
+	ArtifactType  string            `json:"artifactType,omitempty"`
+	Config        Descriptor        `json:"config"`
+	Layers        []Descriptor      `json:"layers"`
+	Subject       *Descriptor       `json:"subject,omitempty"`
+	Annotations   map[string]string `json:"annotations,omitempty"`
+}
+
+// Validate checks the schema version, media type and all descriptors of the
+// manifest. Returns nil if valid, or an error if not valid.
+func (m ImageManifest) Validate() error {
+	if m.SchemaVersion != 2 {
+		return notef(ErrInvalidManifest, "Unsupported schema version %d", m.SchemaVersion)
+	}
+	switch m.MediaType {
+	case "", MediaTypeOCIManifest, MediaTypeDockerManifest:
+	default:
+		return notef(ErrInvalidManifest, "Unexpected media type %#v", m.MediaType)
+	}
+
+	if err := m.Config.Validate(); err != nil {
+		return notef(err, "Invalid config")
+	}
+	for i, layer := range m.Layers {
+		if err := layer.Validate(); err != nil {
+			return notef(err, "Invalid layer %d", i)
+		}
+	}
+	if m.Subject != nil {
+		if err := m.Subject.Validate(); err != nil {
+			return notef(err, "Invalid subject")
+		}
+	}
+	return nil
+}
+
+// ImageIndex is an OCI image index, see
+// https://github.com/opencontainers/image-spec/blob/v1.1.0/image-index.md.
+type ImageIndex struct {
+	SchemaVersion int               `json:"schemaVersion"`
+	MediaType     string            `json:"mediaType,omitempty"`
+	ArtifactType  string            `json:"artifactType,omitempty"`
+	Manifests     []Descriptor      `json:"manifests"`
+	Subject       *Descriptor       `json:"subject,omitempty"`
+	Annotations   map[string]string `json:"annotations,omitempty"`
+}
+
+// Validate checks the schema version, media type and all descriptors of the
+// index. Returns nil if valid, or an error if not valid.
+func (idx ImageIndex) Validate() error {
+	if idx.SchemaVersion != 2 {
+		return notef(ErrInvalidManifest, "Unsupported schema version %d", idx.SchemaVersion)
+	}
+	switch idx.MediaType {
+	case "", MediaTypeOCIIndex, MediaTypeDockerManifestList:
+	default:
+		return notef(ErrInvalidManifest, "Unexpected media type %#v", idx.MediaType)
+	}
+
+	for i, manifest := range idx.Manifests {
+		if err := manifest.Validate(); err != nil {
+			return notef(err, "Invalid manifest %d", i)
+		}
+	}
+	if idx.Subject != nil {
+		if err := idx.Subject.Validate(); err != nil {
+			return notef(err, "Invalid subject")
+		}
+	}
+	return nil
+}
+
+// SelectManifest returns the descriptor of the manifest in the index that
+// best matches the given platform, see Platform.Matches. A manifest built for
+// exactly the requested variant is preferred, then the newest older variant.
+func (idx ImageIndex) SelectManifest(platform Platform) (Descriptor, error) {
+	var compatible *Descriptor
+
+	for i, manifest := range idx.Manifests {
+		if manifest.Platform == nil {
+			continue
+		}
+		p := manifest.Platform.Platform()
+
+		if !p.Matches(platform) {
+			continue
+		}
+		if p.Normalize().effectiveVariant() == platform.Normalize().effectiveVariant() {
+			return manifest, nil
+		}
+		if compatible == nil || compareVariants(p.Normalize().effectiveVariant(), compatible.Platform.Platform().Normalize().effectiveVariant()) > 0 {
+			compatible = &idx.Manifests[i]
+		}
+	}
+
+	if compatible == nil {
+		return Descriptor{}, notef(ErrNoMatchingManifest, "%s", platform.String())
+	}
+	return *compatible, nil
+}
+
+// DockerManifestList is a Docker v2 schema 2 manifest list, the predecessor
+// of the OCI image index, see
+// https://docs.docker.com/registry/spec/manifest-v2-2/#manifest-list.
+type DockerManifestList struct {
+	SchemaVersion int          `json:"schemaVersion"`
+	MediaType     string       `json:"mediaType"`
+	Manifests     []Descriptor `json:"manifests"`
+}
+
+// ImageIndex returns the manifest list as an image index, so that the index
+// methods can be used on it.
+func (l DockerManifestList) ImageIndex() ImageIndex {
+	return ImageIndex{
+		SchemaVersion: l.SchemaVersion,
+		MediaType:     l.MediaType,
+		Manifests:     l.Manifests,
+	}
+}
+
+// Validate checks the schema version, media type and all descriptors of the
+// manifest list. Returns nil if valid, or an error if not valid.
+func (l DockerManifestList) Validate() error {
+	if l.MediaType != MediaTypeDockerManifestList {
+		return notef(ErrInvalidManifest, "Unexpected media type %#v", l.MediaType)
+	}
+	return l.ImageIndex().Validate()
+}
+
+// SelectManifest returns the descriptor of the manifest in the list that best
+// matches the given platform, preferring the exact variant and then the newest
+// older variant like ImageIndex.SelectManifest.
+func (l DockerManifestList) SelectManifest(platform Platform) (Descriptor, error) {
+	return l.ImageIndex().SelectManifest(platform)
+}
@@ -0,0 +1,234 @@
+package generictypes
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/juju/errgo"
+)
+
+const testImageIndex = `{
+  "schemaVersion": 2,
+  "mediaType": "application/vnd.oci.image.index.v1+json",
+  "manifests": [
+    {
+      "mediaType": "application/vnd.oci.image.manifest.v1+json",
+      "digest": "sha256:1111111111111111111111111111111111111111111111111111111111111111",
+      "size": 7143,
+      "platform": {"architecture": "amd64", "os": "linux"}
+    },
+    {
+      "mediaType": "application/vnd.oci.image.manifest.v1+json",
+      "digest": "sha256:2222222222222222222222222222222222222222222222222222222222222222",
+      "size": 7682,
+      "platform": {"architecture": "arm", "os": "linux", "variant": "v6"}
+    },
+    {
+      "mediaType": "application/vnd.oci.image.manifest.v1+json",
+      "digest": "sha256:3333333333333333333333333333333333333333333333333333333333333333",
+      "size": 7682,
+      "platform": {"architecture": "arm", "os": "linux", "variant": "v7"}
+    },
+    {
+      "mediaType": "application/vnd.oci.image.manifest.v1+json",
+      "digest": "sha256:4444444444444444444444444444444444444444444444444444444444444444",
+      "size": 1024,
+      "platform": {"architecture": "amd64", "os": "windows", "os.version": "10.0.17763.1234"}
+    }
+  ],
+  "annotations": {"org.opencontainers.image.ref.name": "1.0"}
+}`
+
+const testImageManifest = `{
+  "schemaVersion": 2,
+  "mediaType": "application/vnd.oci.image.manifest.v1+json",
+  "config": {
+    "mediaType": "application/vnd.oci.image.config.v1+json",
+    "digest": "sha256:b5b2b2c507a0944348e0303114d8d93aaaa081732b86451d9bce1f432a537bc7",
+    "size": 7023
+  },
+  "layers": [
+    {
+      "mediaType": "application/vnd.oci.image.layer.v1.tar+gzip",
+      "digest": "sha256:9834876dcfb05cb167a5c24953eba58c4ac89b1adf57f28f2f9d09af107ee8f0",
+      "size": 32654
+    }
+  ],
+  "subject": {
+    "mediaType": "application/vnd.oci.image.manifest.v1+json",
+    "digest": "sha256:5b0bcabd1ed22e9fb1310cf6c2dec7cdef19f0ad69efa1f392e94a4333501270",
+    "size": 1234
+  }
+}`
+
+func TestImageIndex_JSON(t *testing.T) {
+	var idx ImageIndex
+	if err := json.Unmarshal([]byte(testImageIndex), &idx); err != nil {
+		t.Fatalf("Failed to unmarshal index: %v", err)
+	}
+	if err := idx.Validate(); err != nil {
+		t.Fatalf("Expected valid index, got %v", err)
+	}
+	if len(idx.Manifests) != 4 || idx.Manifests[3].Platform.OSVersion != "10.0.17763.1234" {
+		t.Fatalf("Unexpected manifests %#v", idx.Manifests)
+	}
+
+	data, err := json.Marshal(idx)
+	if err != nil {
+		t.Fatalf("Failed to marshal index: %v", err)
+	}
+	var roundTripped ImageIndex
+	if err := json.Unmarshal(data, &roundTripped); err != nil {
+		t.Fatalf("Failed to unmarshal index: %v", err)
+	}
+	if roundTripped.Annotations["org.opencontainers.image.ref.name"] != "1.0" || roundTripped.Manifests[1].Platform.Variant != "v6" {
+		t.Fatalf("Index changed on round trip: %s", string(data))
+	}
+}
+
+func TestImageManifest_JSON(t *testing.T) {
+	var manifest ImageManifest
+	if err := json.Unmarshal([]byte(testImageManifest), &manifest); err != nil {
+		t.Fatalf("Failed to unmarshal manifest: %v", err)
+	}
+	if err := manifest.Validate(); err != nil {
+		t.Fatalf("Expected valid manifest, got %v", err)
+	}
+	if manifest.Config.MediaType != MediaTypeOCIConfig || manifest.Layers[0].MediaType != MediaTypeOCILayerGzip || manifest.Subject == nil {
+		t.Fatalf("Unexpected manifest %#v", manifest)
+	}
+
+	manifest.Layers[0].Digest = "sha256"
+	if err := manifest.Validate(); !errors.Is(err, ErrInvalidManifest) || errgo.Cause(err) != ErrInvalidManifest {
+		t.Fatalf("Expected ErrInvalidManifest for invalid layer digest, got %v", err)
+	}
+
+	manifest = ImageManifest{SchemaVersion: 1}
+	if err := manifest.Validate(); !errors.Is(err, ErrInvalidManifest) {
+		t.Fatalf("Expected ErrInvalidManifest for schema version 1, got %v", err)
+	}
+}
+
+var selectedManifests = []struct {
+	Platform       string
+	ExpectedDigest string
+}{
+	{"linux/amd64", "sha256:1111111111111111111111111111111111111111111111111111111111111111"},
+	{"linux/x86_64/v3", "sha256:1111111111111111111111111111111111111111111111111111111111111111"},
+	{"linux/arm", "sha256:3333333333333333333333333333333333333333333333333333333333333333"},
+	{"linux/arm/v6", "sha256:2222222222222222222222222222222222222222222222222222222222222222"},
+	{"linux/arm/v7", "sha256:3333333333333333333333333333333333333333333333333333333333333333"},
+	{"linux/arm/v8", "sha256:3333333333333333333333333333333333333333333333333333333333333333"},
+	{"windows/amd64:10.0.17763", "sha256:4444444444444444444444444444444444444444444444444444444444444444"},
+	{"windows/amd64", "sha256:4444444444444444444444444444444444444444444444444444444444444444"},
+	{"linux/arm64", ""},
+	{"linux/arm/v5", ""},
+	{"windows/amd64:10.0.14393", ""},
+}
+
+func TestImageIndex_SelectManifest(t *testing.T) {
+	var idx ImageIndex
+	if err := json.Unmarshal([]byte(testImageIndex), &idx); err != nil {
+		t.Fatalf("Failed to unmarshal index: %v", err)
+	}
+
+	for _, data := range selectedManifests {
+		manifest, err := idx.SelectManifest(MustParsePlatform(data.Platform))
+		if data.ExpectedDigest == "" {
+			if !errors.Is(err, ErrNoMatchingManifest) || errgo.Cause(err) != ErrNoMatchingManifest {
+				t.Fatalf("Expected ErrNoMatchingManifest for platform %s, got %#v, %v", data.Platform, manifest, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Expected no error for platform %s, got %v", data.Platform, err)
+		}
+		if manifest.Digest != data.ExpectedDigest {
+			t.Fatalf("Expected digest '%s' for platform %s, got '%s'", data.ExpectedDigest, data.Platform, manifest.Digest)
+		}
+	}
+}
+
+func TestDockerManifestList(t *testing.T) {
+	data := `{
+  "schemaVersion": 2,
+  "mediaType": "application/vnd.docker.distribution.manifest.list.v2+json",
+  "manifests": [
+    {
+      "mediaType": "application/vnd.docker.distribution.manifest.v2+json",
+      "digest": "sha256:e692418e4cbaf90ca69d05a66403747baa33ee08806650b51fab815ad7fc331f",
+      "size": 7143,
+      "platform": {"architecture": "ppc64le", "os": "linux"}
+    }
+  ]
+}`
+
+	var list DockerManifestList
+	if err := json.Unmarshal([]byte(data), &list); err != nil {
+		t.Fatalf("Failed to unmarshal manifest list: %v", err)
+	}
+	if err := list.Validate(); err != nil {
+		t.Fatalf("Expected valid manifest list, got %v", err)
+	}
+
+	manifest, err := list.SelectManifest(MustParsePlatform("linux/ppc64le"))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if manifest.MediaType != MediaTypeDockerManifest {
+		t.Fatalf("Unexpected manifest %#v", manifest)
+	}
+
+	list.MediaType = MediaTypeOCIIndex
+	if err := list.Validate(); !errors.Is(err, ErrInvalidManifest) {
+		t.Fatalf("Expected ErrInvalidManifest for wrong media type, got %v", err)
+	}
+}
+
+func TestDescriptor_Verify(t *testing.T) {
+	content := []byte(`{"schemaVersion":2}`)
+
+	descriptor := Descriptor{
+		MediaType: MediaTypeOCIManifest,
+		Digest:    testManifestDigest(string(content)),
+		Size:      int64(len(content)),
+	}
+	if err := descriptor.Verify(content); err != nil {
+		t.Fatalf("Expected content to verify, got %v", err)
+	}
+
+	if err := descriptor.Verify([]byte(`{"schemaVersion":3}`)); !errors.Is(err, ErrDigestMismatch) || errgo.Cause(err) != ErrDigestMismatch {
+		t.Fatalf("Expected ErrDigestMismatch for different content, got %v", err)
+	}
+	if err := descriptor.Verify(append(content, ' ')); !errors.Is(err, ErrDigestMismatch) {
+		t.Fatalf("Expected ErrDigestMismatch for different size, got %v", err)
+	}
+
+	descriptor.Digest = "sha512:cf83e1357eefb8bdf1542850d66d8007d620e4050b5715dc83f4a921d36ce9ce47d0d13c5d85f2b0ff8318d2877eec2f63b931bd47417a81a538327af927da3e"
+	descriptor.Size = 0
+	if err := descriptor.Verify([]byte{}); err != nil {
+		t.Fatalf("Expected empty content to verify with sha512, got %v", err)
+	}
+
+	descriptor.Digest = "md5:d41d8cd98f00b204e9800998ecf8427e"
+	if err := descriptor.Verify([]byte{}); !errors.Is(err, ErrUnsupportedDigest) {
+		t.Fatalf("Expected ErrUnsupportedDigest for unsupported digest algorithm, got %v", err)
+	}
+}
+
+func TestDescriptor_Reference(t *testing.T) {
+	descriptor := Descriptor{
+		MediaType: MediaTypeOCIManifest,
+		Digest:    testDigest,
+		Size:      7143,
+	}
+
+	img, err := descriptor.Reference(MustParseDockerImage("quay.io/giantswarm/app:1.0"))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if img.String() != "quay.io/giantswarm/app@"+testDigest {
+		t.Fatalf("Unexpected reference '%s'", img.String())
+	}
+}
@@ -0,0 +1,284 @@
+package generictypes
+
+import (
+	"github.com/juju/errgo"
+
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrInvalidPlatform = errgo.New("Not a valid platform. Format: <os>/<arch>[/<variant>][:<os version>]")
+)
+
+// knownPlatforms maps every known GOOS to its known GOARCHs, see `go tool dist list`.
+var knownPlatforms = map[string][]string{
+	"aix":       {"ppc64"},
+	"android":   {"386", "amd64", "arm", "arm64"},
+	"darwin":    {"amd64", "arm64"},
+	"dragonfly": {"amd64"},
+	"freebsd":   {"386", "amd64", "arm", "arm64", "riscv64"},
+	"illumos":   {"amd64"},
+	"ios":       {"amd64", "arm64"},
+	"js":        {"wasm"},
+	"linux":     {"386", "amd64", "arm", "arm64", "loong64", "mips", "mips64", "mips64le", "mipsle", "ppc64", "ppc64le", "riscv64", "s390x"},
+	"netbsd":    {"386", "amd64", "arm", "arm64"},
+	"openbsd":   {"386", "amd64", "arm", "arm64", "ppc64", "riscv64"},
+	"plan9":     {"386", "amd64", "arm"},
+	"solaris":   {"amd64"},
+	"wasip1":    {"wasm"},
+	"windows":   {"386", "amd64", "arm", "arm64"},
+}
+
+// knownVariants maps architectures with variants to the pattern of valid
+// variants and the variant assumed when none is given.
+var knownVariants = map[string]struct {
+	Pattern *regexp.Regexp
+	Default string
+}{
+	"arm":     {regexp.MustCompile(`^v[5-8]$`), "v7"},
+	"arm64":   {regexp.MustCompile(`^v[89](\.[0-9])?$`), "v8"},
+	"amd64":   {regexp.MustCompile(`^v[1-4]$`), "v1"},
+	"riscv64": {regexp.MustCompile(`^rva2[023]u64$`), "rva20u64"},
+}
+
+// architectureAliases maps common non-Go architecture names, e.g. from
+// `uname -m`, to the architecture and variant Go and OCI use.
+var architectureAliases = map[string][2]string{
+	"aarch64": {"arm64", ""},
+	"x86_64":  {"amd64", ""},
+	"x86-64":  {"amd64", ""},
+	"x86":     {"386", ""},
+	"i386":    {"386", ""},
+	"i686":    {"386", ""},
+	"armhf":   {"arm", "v7"},
+	"armel":   {"arm", "v6"},
+	"armv5l":  {"arm", "v5"},
+	"armv6l":  {"arm", "v6"},
+	"armv7l":  {"arm", "v7"},
+	"armv8l":  {"arm", "v8"},
+}
+
+// PatternOSVersion matches windows versions like "10.0.17763" or "10.0.17763.1234".
+var PatternOSVersion = regexp.MustCompile(`^[0-9]+(\.[0-9]+){1,3}$`)
+
+func MustParsePlatform(platform string) Platform {
+	p, err := ParsePlatform(platform)
+	if err != nil {
+		panic(errgo.Mask(err))
+	}
+	return p
+}
+
+func ParsePlatform(platform string) (Platform, error) {
+	var p Platform
+	err := p.parse(platform)
+	return p, err
+}
+
+// Platform describes the platform an image is built for, e.g. "linux/amd64",
+// "linux/arm64/v8" or "windows/amd64:10.0.17763".
+type Platform struct {
+	OS           string // The operating system, e.g. "linux"
+	Architecture string // The CPU architecture, e.g. "arm64"
+	Variant      string // The CPU variant, e.g. "v7"
+	OSVersion    string // The operating system version, only used for windows
+}
+
+func (p Platform) MarshalJSON() ([]byte, error) {
+	return json.Marshal(p.String())
+}
+
+func (p *Platform) UnmarshalJSON(data []byte) error {
+	var input string
+
+	if err := json.Unmarshal(data, &input); err != nil {
+		return err
+	}
+	return p.parse(input)
+}
+
+// Returns the platform as <os>/<arch>[/<variant>][:<os version>]
+func (p Platform) String() string {
+	s := p.OS + "/" + p.Architecture
+	if p.Variant != "" {
+		s += "/" + p.Variant
+	}
+	if p.OSVersion != "" {
+		s += ":" + p.OSVersion
+	}
+	return s
+}
+
+// Validate checks that the given platform is a known combination of OS,
+// architecture and variant. Returns nil if valid, or an error if not valid.
+func (p Platform) Validate() error {
+	tmp := Platform{}
+	if err := tmp.parse(p.String()); err != nil {
+		return err
+	}
+	if tmp != p {
+		return newPlatformError(p.String(), "Platform %#v is not normalized", p.String())
+	}
+	return nil
+}
+
+// Normalize returns the platform with architecture aliases like "aarch64"
+// replaced and numeric variants like "7" prefixed with "v".
+func (p Platform) Normalize() Platform {
+	p.OS = strings.ToLower(p.OS)
+	p.Architecture = strings.ToLower(p.Architecture)
+	p.Variant = strings.ToLower(p.Variant)
+
+	if alias, ok := architectureAliases[p.Architecture]; ok {
+		p.Architecture = alias[0]
+		if p.Variant == "" {
+			p.Variant = alias[1]
+		}
+	}
+	if p.Variant != "" && p.Variant[0] >= '0' && p.Variant[0] <= '9' {
+		p.Variant = "v" + p.Variant
+	}
+	if p.Architecture == "arm64" {
+		p.Variant = strings.TrimSuffix(p.Variant, ".0")
+	}
+	return p
+}
+
+// Matches returns true if an image built for this platform can run on the
+// requested platform. OS and architecture have to be equal. Images built for
+// an older variant run on newer ones, e.g. a linux/arm/v6 image satisfies a
+// request for linux/arm/v7. An OS version only has to match if both
+// platforms specify it.
+func (p Platform) Matches(requested Platform) bool {
+	p = p.Normalize()
+	requested = requested.Normalize()
+
+	if p.OS != requested.OS || p.Architecture != requested.Architecture {
+		return false
+	}
+	if p.OSVersion != "" && requested.OSVersion != "" && !sameOSBuild(p.OSVersion, requested.OSVersion) {
+		return false
+	}
+	return compareVariants(p.effectiveVariant(), requested.effectiveVariant()) <= 0
+}
+
+// effectiveVariant returns the variant, or the default variant of the
+// architecture if none is set.
+func (p Platform) effectiveVariant() string {
+	if p.Variant == "" {
+		return knownVariants[p.Architecture].Default
+	}
+	return p.Variant
+}
+
+func (p *Platform) parse(input string) error {
+	if len(input) == 0 {
+		return newPlatformError(input, "Zero length")
+	}
+	if strings.ContainsAny(input, " \t\n") {
+		return newPlatformError(input, "No whitespaces allowed")
+	}
+
+	var parsed Platform
+
+	splitByVersionSeparator := strings.Split(input, ":")
+	switch len(splitByVersionSeparator) {
+	case 2:
+		parsed.OSVersion = splitByVersionSeparator[1]
+	case 1:
+	default:
+		return newPlatformError(input, "Too many colons")
+	}
+
+	splitByPath := strings.Split(splitByVersionSeparator[0], "/")
+	switch len(splitByPath) {
+	case 3:
+		parsed.Variant = splitByPath[2]
+		fallthrough
+	case 2:
+		parsed.OS = splitByPath[0]
+		parsed.Architecture = splitByPath[1]
+	default:
+		return newPlatformError(input, "Invalid format")
+	}
+
+	parsed = parsed.Normalize()
+
+	arches, ok := knownPlatforms[parsed.OS]
+	if !ok {
+		return newPlatformError(input, "Unknown OS %#v", parsed.OS)
+	}
+	if !containsString(arches, parsed.Architecture) {
+		return newPlatformError(input, "Unknown architecture %#v for OS %#v", parsed.Architecture, parsed.OS)
+	}
+	if parsed.Variant != "" {
+		variants, ok := knownVariants[parsed.Architecture]
+		if !ok || !variants.Pattern.MatchString(parsed.Variant) {
+			return newPlatformError(input, "Unknown variant %#v for architecture %#v", parsed.Variant, parsed.Architecture)
+		}
+	}
+	if parsed.OSVersion != "" {
+		if parsed.OS != "windows" {
+			return newPlatformError(input, "OS version is only supported for windows")
+		}
+		if !PatternOSVersion.MatchString(parsed.OSVersion) {
+			return newPlatformError(input, "Invalid OS version %#v", parsed.OSVersion)
+		}
+	}
+
+	*p = parsed
+	return nil
+}
+
+// compareVariants compares variants of the same architecture, returning a
+// negative number if a is older than b, 0 if equal and a positive number if
+// a is newer than b. Variants like "v8.2" are compared numerically.
+func compareVariants(a, b string) int {
+	if !strings.HasPrefix(a, "v") || !strings.HasPrefix(b, "v") {
+		return strings.Compare(a, b)
+	}
+
+	aParts := strings.Split(a[1:], ".")
+	bParts := strings.Split(b[1:], ".")
+	for i := 0; i < len(aParts) || i < len(bParts); i++ {
+		var aNum, bNum int
+		if i < len(aParts) {
+			aNum, _ = strconv.Atoi(aParts[i])
+		}
+		if i < len(bParts) {
+			bNum, _ = strconv.Atoi(bParts[i])
+		}
+		if aNum != bNum {
+			return aNum - bNum
+		}
+	}
+	return 0
+}
+
+// sameOSBuild returns true if both windows versions share major, minor and
+// build number, which is required for process isolated containers.
+func sameOSBuild(a, b string) bool {
+	aParts := strings.Split(a, ".")
+	bParts := strings.Split(b, ".")
+	for i := 0; i < 3; i++ {
+		if i >= len(aParts) || i >= len(bParts) {
+			return len(aParts) == len(bParts)
+		}
+		if aParts[i] != bParts[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,151 @@
+package generictypes
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+var platformParsings = []struct {
+	Input string
+
+	ExpectedOS           string
+	ExpectedArchitecture string
+	ExpectedVariant      string
+	ExpectedOSVersion    string
+	ExpectedString       string
+}{
+	{"linux/amd64", "linux", "amd64", "", "", "linux/amd64"},
+	{"linux/arm64/v8", "linux", "arm64", "v8", "", "linux/arm64/v8"},
+	{"linux/arm/v7", "linux", "arm", "v7", "", "linux/arm/v7"},
+	{"windows/amd64:10.0.17763", "windows", "amd64", "", "10.0.17763", "windows/amd64:10.0.17763"},
+	{"linux/aarch64", "linux", "arm64", "", "", "linux/arm64"},
+	{"linux/x86_64", "linux", "amd64", "", "", "linux/amd64"},
+	{"linux/i686", "linux", "386", "", "", "linux/386"},
+	{"linux/armhf", "linux", "arm", "v7", "", "linux/arm/v7"},
+	{"linux/armv6l", "linux", "arm", "v6", "", "linux/arm/v6"},
+	{"Linux/ARM/7", "linux", "arm", "v7", "", "linux/arm/v7"},
+	{"linux/arm64/v8.0", "linux", "arm64", "v8", "", "linux/arm64/v8"},
+	{"linux/arm64/v8.2", "linux", "arm64", "v8.2", "", "linux/arm64/v8.2"},
+	{"linux/amd64/v3", "linux", "amd64", "v3", "", "linux/amd64/v3"},
+	{"linux/riscv64/rva22u64", "linux", "riscv64", "rva22u64", "", "linux/riscv64/rva22u64"},
+}
+
+func TestPlatformParsing(t *testing.T) {
+	for _, data := range platformParsings {
+		platform, err := ParsePlatform(data.Input)
+		if err != nil {
+			t.Fatalf("Failed to parse platform %#v: %v", data.Input, err)
+		}
+
+		if platform.OS != data.ExpectedOS {
+			t.Fatalf("Unexpected OS: '%s' but got '%s'", data.ExpectedOS, platform.OS)
+		}
+		if platform.Architecture != data.ExpectedArchitecture {
+			t.Fatalf("Unexpected architecture: '%s' but got '%s'", data.ExpectedArchitecture, platform.Architecture)
+		}
+		if platform.Variant != data.ExpectedVariant {
+			t.Fatalf("Unexpected variant: '%s' but got '%s'", data.ExpectedVariant, platform.Variant)
+		}
+		if platform.OSVersion != data.ExpectedOSVersion {
+			t.Fatalf("Unexpected OS version: '%s' but got '%s'", data.ExpectedOSVersion, platform.OSVersion)
+		}
+		if platform.String() != data.ExpectedString {
+			t.Fatalf("Unexpected string conversion output: '%s' but got '%s'", data.ExpectedString, platform.String())
+		}
+		if err := platform.Validate(); err != nil {
+			t.Fatalf("Expected parsed platform %s to be valid, got %v", platform, err)
+		}
+	}
+}
+
+var invalidPlatforms = []struct {
+	Input string
+}{
+	{""},
+	{"linux"},
+	{"linux/"},
+	{"/amd64"},
+	{"linux/amd64/v3/extra"},
+	{"plan10/amd64"},
+	{"linux/sparc"},
+	{"darwin/s390x"},
+	{"linux/386/v7"},
+	{"linux/arm/v9"},
+	{"linux/arm64/v7"},
+	{"linux/amd64:10.0.17763"},
+	{"windows/amd64:latest"},
+	{"windows/amd64:10:0"},
+	{"linux/ amd64"},
+}
+
+func TestPlatformParsingErrors(t *testing.T) {
+	for _, data := range invalidPlatforms {
+		platform, err := ParsePlatform(data.Input)
+		if err == nil {
+			t.Fatalf("Expected error for input: %v\nBut got: %#v", data.Input, platform)
+		}
+	}
+}
+
+func TestPlatformValidate(t *testing.T) {
+	if err := (Platform{OS: "linux", Architecture: "aarch64"}).Validate(); err == nil {
+		t.Fatalf("Expected error for unnormalized platform")
+	}
+	if err := (Platform{OS: "linux", Architecture: "arm64"}).Validate(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+}
+
+func TestPlatformJSON(t *testing.T) {
+	var platforms []Platform
+	if err := json.Unmarshal([]byte(`["linux/aarch64","windows/amd64:10.0.17763"]`), &platforms); err != nil {
+		t.Fatalf("Failed to unmarshal platforms: %v", err)
+	}
+
+	data, err := json.Marshal(platforms)
+	if err != nil {
+		t.Fatalf("Failed to marshal platforms: %v", err)
+	}
+	if string(data) != `["linux/arm64","windows/amd64:10.0.17763"]` {
+		t.Fatalf("Unexpected JSON %s", string(data))
+	}
+
+	var platform Platform
+	if err := json.Unmarshal([]byte(`"linux/sparc"`), &platform); err == nil {
+		t.Fatalf("Expected error for invalid platform, got %#v", platform)
+	}
+}
+
+var platformMatches = []struct {
+	Image     string
+	Requested string
+	Expected  bool
+}{
+	{"linux/amd64", "linux/amd64", true},
+	{"linux/amd64", "linux/x86_64", true},
+	{"linux/amd64", "linux/amd64/v3", true},
+	{"linux/amd64/v3", "linux/amd64", false},
+	{"linux/amd64", "linux/arm64", false},
+	{"linux/amd64", "windows/amd64", false},
+	{"linux/arm64", "linux/arm64/v8", true},
+	{"linux/arm64/v8", "linux/aarch64", true},
+	{"linux/arm64/v8", "linux/arm64/v8.2", true},
+	{"linux/arm64/v9", "linux/arm64/v8.2", false},
+	{"linux/arm/v6", "linux/arm/v7", true},
+	{"linux/arm/v7", "linux/arm/v6", false},
+	{"linux/arm", "linux/arm/v7", true},
+	{"linux/arm/v8", "linux/arm", false},
+	{"windows/amd64:10.0.17763.1234", "windows/amd64:10.0.17763", true},
+	{"windows/amd64:10.0.17763", "windows/amd64:10.0.14393", false},
+	{"windows/amd64:10.0.17763", "windows/amd64", true},
+	{"windows/amd64", "windows/amd64:10.0.17763", true},
+}
+
+func TestPlatformMatches(t *testing.T) {
+	for _, data := range platformMatches {
+		matches := MustParsePlatform(data.Image).Matches(MustParsePlatform(data.Requested))
+		if matches != data.Expected {
+			t.Fatalf("Expected %s matching %s to be %v, got %v", data.Image, data.Requested, data.Expected, matches)
+		}
+	}
+}
@@ -2,9 +2,12 @@ package generictypes
 
 import (
 	"github.com/juju/errgo"
+	"gopkg.in/yaml.v3"
 
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
 	"strconv"
 	"strings"
 )
@@ -17,101 +20,303 @@ func MustParseDockerPort(port string) DockerPort {
 	return result
 }
 
+// NewDockerPort returns the given port, which is formatted as
+// "<port>/<protocol>". An error is returned for port 0 or an unknown protocol.
+func NewDockerPort(port uint16, protocol string) (DockerPort, error) {
+	return ParseDockerPort(strconv.FormatUint(uint64(port), 10) + "/" + protocol)
+}
+
+func MustNewDockerPort(port uint16, protocol string) DockerPort {
+	result, err := NewDockerPort(port, protocol)
+	if err != nil {
+		panic(err.Error())
+	}
+	return result
+}
+
 func ParseDockerPort(port string) (DockerPort, error) {
 	var result DockerPort
 	if err := parseDockerPort(port, &result); err != nil {
-		return result, errgo.Mask(err)
+		return result, err
 	}
 	return result, nil
 }
 
-type modePortJSONFormat int
+// PortFormat is the way a DockerPort is marshalled as JSON or YAML.
+type PortFormat int
 
 const (
-	modePortJsonDocker modePortJSONFormat = 0
-	modePortJsonNumber modePortJSONFormat = 1
-	modePortJsonString modePortJSONFormat = 2
+	PortFormatDocker PortFormat = 0 // "<port>/<protocol>", e.g. "6379/tcp"
+	PortFormatNumber PortFormat = 1 // <port>, e.g. 6379, only for tcp ports
+	PortFormatString PortFormat = 2 // "<port>", e.g. "6379", only for tcp ports
 )
 
+func (f PortFormat) String() string {
+	switch f {
+	case PortFormatDocker:
+		return "docker"
+	case PortFormatNumber:
+		return "number"
+	case PortFormatString:
+		return "string"
+	default:
+		return fmt.Sprintf("PortFormat(%d)", int(f))
+	}
+}
+
 const (
 	ProtocolTCP = "tcp"
 	ProtocolUDP = "udp"
 )
 
 type DockerPort struct {
-	// The port number.
+	// The port number in decimal without leading zeros, see Number().
 	Port string
 
 	// The protocol to use. "tcp" or "udp"
 	Protocol string
 
-	// How to format this port when marshalling as JSON.
+	// How to format this port when marshalling as JSON, see Format() and
+	// WithFormat().
 	// 0 = format as string - ("port/protocol")
 	// 1 = format as int - port
 	// 2 = format as short port - "<port>"
 	//
 	// This is needed, because we need to marshal our ports the way we parsed them.
-	// Otherwise the diff check in CheckForUnknownFields() would trigger when we
+	// Otherwise the diff check in CheckJSONRoundTrip() would trigger when we
 	// marshal `6379` as `"6379/tcp"`.
-	formatJsonMode modePortJSONFormat
+	formatJsonMode PortFormat
+
+	// The service name the port was parsed from with Services.ParseDockerPort,
+	// e.g. "http". It replaces the number when marshalling the port as JSON or
+	// YAML, see ServiceName().
+	serviceName string
 }
 
 func (d DockerPort) String() string {
 	return fmt.Sprintf("%s/%s", d.Port, d.Protocol)
 }
 
+// ServiceName returns the service name the port was parsed from, e.g. "http"
+// for "http/tcp", or "" if it was given as number. The port is marshalled as
+// the name only if SetDecodeServices enabled a table that resolves it to the
+// same port, so that decoding it again returns the same port.
+func (d DockerPort) ServiceName() string {
+	return d.serviceName
+}
+
+// serviceString returns the service name in the format of the port, i.e. as
+// "<name>" or "<name>/<protocol>". It returns "" if the table set with
+// SetDecodeServices does not resolve the name to the port, or if none is set.
+func (d DockerPort) serviceString() string {
+	if d.serviceName == "" {
+		return ""
+	}
+	services := getDecodeServices()
+	if services == nil {
+		return ""
+	}
+	if port, ok := services.Lookup(d.serviceName, d.Protocol); !ok || port.Port != d.Port {
+		return ""
+	}
+	if d.formatJsonMode == PortFormatString {
+		return d.serviceName
+	}
+	return d.serviceName + "/" + d.Protocol
+}
+
 func (d DockerPort) MarshalJSON() ([]byte, error) {
+	if err := d.checkFormat(d.formatJsonMode); err != nil {
+		return nil, err
+	}
+	if service := d.serviceString(); service != "" {
+		return json.Marshal(service)
+	}
 	switch d.formatJsonMode {
-	case modePortJsonDocker:
+	case PortFormatNumber:
+		number := d.Number()
+		if number == 0 {
+			return nil, newPortError(d.Port, ErrInvalidPortNumber, "Port must be a number between 1 and 65535, got '%s'", d.Port)
+		}
+		return strconv.AppendUint(nil, uint64(number), 10), nil
+	case PortFormatString:
+		return json.Marshal(d.Port)
+	default:
 		return json.Marshal(d.String())
-	case modePortJsonNumber:
-		if d.Protocol != ProtocolTCP {
-			return nil, errgo.Newf("Invalid protocol for formatJsonMode=number")
+	}
+}
+
+// UnmarshalJSON accepts numbers like 80 and strings like "80" or "80/tcp", as
+// well as service names like "http" or "dns/udp" if SetDecodeServices enabled
+// them. null leaves the port unchanged, like for other types.
+func (d *DockerPort) UnmarshalJSON(data []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return newPortError(string(data), ErrInvalidPortFormat, "Invalid JSON for port: %v", err)
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return newPortError(string(data), ErrInvalidPortFormat, "Invalid JSON for port, unexpected data in %s", data)
+	}
+
+	var parsed DockerPort
+	switch value := value.(type) {
+	case nil:
+		return nil
+	case string:
+		if err := parseDockerPortOrService(value, &parsed); err != nil {
+			return err
 		}
-		i, err := strconv.Atoi(d.Port)
-		if err != nil {
-			return nil, errgo.Mask(err)
+	case json.Number:
+		input := value.String()
+		if strings.Trim(strings.TrimPrefix(input, "-"), "0123456789") != "" {
+			return newPortError(input, ErrInvalidPortNumber, "Port must be an integer, got %s", input)
 		}
-		return json.Marshal(i)
-	case modePortJsonString:
-		if d.Protocol != ProtocolTCP {
-			return nil, errgo.Newf("Invalid protocol for formatJsonMode=number")
+		if err := parseDockerPort(input, &parsed); err != nil {
+			return err
 		}
-		return json.Marshal(d.Port)
+		parsed.formatJsonMode = PortFormatNumber
 	default:
-		panic("Invalid 'formatJsonMode'")
+		return newPortError(string(data), ErrInvalidPortFormat, "Port must be a number or string, got %s", data)
 	}
+
+	*d = parsed
+	return nil
 }
 
-func (d *DockerPort) UnmarshalJSON(data []byte) error {
-	wasNumber := false
-	if data[0] != '"' {
-		newData := []byte{}
-		newData = append(newData, '"')
-		newData = append(newData, data...)
-		newData = append(newData, '"')
+// MarshalText implements encoding.TextMarshaler. Ports are always formatted
+// as "<port>/<protocol>", so that e.g. a map[DockerPort]struct{} encodes like
+// the ExposedPorts object of the Docker Engine API.
+func (d DockerPort) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler, accepting "<port>" and
+// "<port>/<protocol>" as well as service names like UnmarshalJSON. Since
+// MarshalText always writes the long form, the port is marshalled as
+// "<port>/<protocol>" to JSON as well.
+func (d *DockerPort) UnmarshalText(data []byte) error {
+	var parsed DockerPort
+	if err := parseDockerPortOrService(string(data), &parsed); err != nil {
+		return err
+	}
+	parsed.formatJsonMode = PortFormatDocker
+	*d = parsed
+	return nil
+}
+
+// MarshalYAML implements yaml.Marshaler. Like MarshalJSON, the port is
+// formatted the way it was parsed, i.e. as 80, "80" or "80/tcp".
+func (d DockerPort) MarshalYAML() (interface{}, error) {
+	if err := d.checkFormat(d.formatJsonMode); err != nil {
+		return nil, err
+	}
+	if service := d.serviceString(); service != "" {
+		return service, nil
+	}
+	switch d.formatJsonMode {
+	case PortFormatNumber:
+		number := d.Number()
+		if number == 0 {
+			return nil, newPortError(d.Port, ErrInvalidPortNumber, "Port must be a number between 1 and 65535, got '%s'", d.Port)
+		}
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: strconv.FormatUint(uint64(number), 10)}, nil
+	case PortFormatString:
+		// The string tag makes the encoder quote the port.
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: d.Port}, nil
+	default:
+		return d.String(), nil
+	}
+}
 
-		data = newData
+// Format returns how the port is marshalled as JSON or YAML. Parsed ports
+// keep the format they were parsed from, while ports built from scratch use
+// PortFormatDocker.
+func (d DockerPort) Format() PortFormat {
+	return d.formatJsonMode
+}
 
-		wasNumber = true
+// WithFormat returns a copy of the port that is marshalled in the given
+// format. An error is returned if the format cannot represent the port, i.e.
+// for udp ports in PortFormatNumber or PortFormatString. The service name of
+// the port is dropped, so it is marshalled as number.
+func (d DockerPort) WithFormat(format PortFormat) (DockerPort, error) {
+	if err := d.checkFormat(format); err != nil {
+		return DockerPort{}, err
 	}
+	d.formatJsonMode = format
+	d.serviceName = ""
+	return d, nil
+}
 
-	var s string
-	if err := json.Unmarshal(data, &s); err != nil {
-		return errgo.Mask(err)
+// checkFormat returns an error if the port cannot be marshalled in the given
+// format.
+func (d DockerPort) checkFormat(format PortFormat) error {
+	switch format {
+	case PortFormatDocker:
+		return nil
+	case PortFormatNumber, PortFormatString:
+		if d.Protocol != ProtocolTCP {
+			return newPortError(d.String(), ErrUnsupportedPortFormat, "Port %s cannot be formatted as %s, only tcp ports can", d.String(), format)
+		}
+		return nil
+	default:
+		return newPortError(d.String(), ErrUnsupportedPortFormat, "Unknown port format %s", format)
 	}
+}
 
-	if err := parseDockerPort(s, d); err != nil {
-		return errgo.Mask(err)
+// UnmarshalYAML implements yaml.Unmarshaler, accepting 80, "80" and
+// "80/tcp" as well as service names like UnmarshalJSON. Errors contain the
+// line and column of the port.
+func (d *DockerPort) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.ScalarNode {
+		return yamlNodeError(node, errgo.Newf("Port must be a number or string"))
 	}
 
-	if wasNumber {
-		d.formatJsonMode = modePortJsonNumber
+	var parsed DockerPort
+	if err := parseDockerPortOrService(node.Value, &parsed); err != nil {
+		return yamlNodeError(node, err)
+	}
+	switch node.ShortTag() {
+	case "!!int":
+		parsed.formatJsonMode = PortFormatNumber
+	case "!!str":
+	default:
+		return yamlNodeError(node, errgo.Newf("Port must be a number or string, got '%s'", node.Value))
 	}
 
+	*d = parsed
+	return nil
+}
+
+// Validate checks that the given port has a valid number in canonical
+// decimal form and a valid protocol. Returns nil if valid, or an error if not
+// valid.
+func (d DockerPort) Validate() error {
+	var tmp DockerPort
+	if err := parseDockerPort(d.String(), &tmp); err != nil {
+		return err
+	}
+	if tmp.Port != d.Port {
+		return newPortError(d.String(), ErrInvalidPortNumber, "Port must not have leading zeros, got '%s'", d.Port)
+	}
 	return nil
 }
 
+// Number returns the port number, or 0 if Port is not a valid port number.
+func (d DockerPort) Number() uint16 {
+	if d.Port == "" || strings.Trim(d.Port, "0123456789") != "" {
+		return 0
+	}
+	number, err := strconv.ParseUint(d.Port, 10, 16)
+	if err != nil {
+		return 0
+	}
+	return uint16(number)
+}
+
 // Empty returns true if this port is equal to "", false otherwise.
 func (d *DockerPort) Empty() bool {
 	return d.Port == "" // Protocol can be set automatically to TCP so don't check that
@@ -128,29 +333,33 @@ func parseDockerPort(input string, dp *DockerPort) error {
 	case 1:
 		dp.Port = s[0]
 		dp.Protocol = ProtocolTCP
-		dp.formatJsonMode = modePortJsonString
+		dp.formatJsonMode = PortFormatString
 	case 2:
 		dp.Port = s[0]
 		dp.Protocol = s[1]
-		dp.formatJsonMode = modePortJsonDocker
+		dp.formatJsonMode = PortFormatDocker
 	default:
-		return errgo.Newf("Invalid format, must be either <port> or <port>/<prot>, got '%s'", input)
+		return newPortError(input, ErrInvalidPortFormat, "Invalid format, must be either <port> or <port>/<prot>, got '%s'", input)
 	}
 
-	if parsedPort, err := strconv.Atoi(dp.Port); err != nil {
-		return errgo.Notef(err, "Port must be a number, got '%s'", dp.Port)
-	} else if parsedPort < 1 || parsedPort > 65535 {
-		return errgo.Notef(err, "Port must be a number between 1 and 65535, got '%s'", dp.Port)
+	// Signs are rejected, leading zeros are dropped, like Docker does.
+	if dp.Port == "" || strings.Trim(dp.Port, "0123456789") != "" {
+		return newPortError(input, ErrInvalidPortNumber, "Port must be a number, got '%s'", dp.Port)
+	}
+	parsedPort, err := strconv.ParseUint(dp.Port, 10, 16)
+	if err != nil || parsedPort < 1 {
+		return newPortError(input, ErrInvalidPortNumber, "Port must be a number between 1 and 65535, got '%s'", dp.Port)
 	}
+	dp.Port = strconv.FormatUint(parsedPort, 10)
 
 	switch dp.Protocol {
 	case "":
-		return errgo.Newf("Protocol must not be empty.")
+		return newPortError(input, ErrInvalidProtocol, "Protocol must not be empty.")
 	case ProtocolUDP:
 		fallthrough
 	case ProtocolTCP:
 		return nil
 	default:
-		return errgo.Newf("Unknown protocol: '%s' in '%s'", dp.Protocol, input)
+		return newPortError(input, ErrInvalidProtocol, "Unknown protocol: '%s' in '%s'", dp.Protocol, input)
 	}
 }
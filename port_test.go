@@ -2,7 +2,11 @@ package generictypes
 
 import (
 	"encoding/json"
+	"errors"
+	"strings"
 	"testing"
+
+	"gopkg.in/yaml.v3"
 )
 
 var validPorts = []struct {
@@ -117,3 +121,404 @@ func TestDockerPort_Empty(t *testing.T) {
 		}
 	}
 }
+
+func TestDockerPort_TextMarshalling(t *testing.T) {
+	exposedPorts := map[DockerPort]struct{}{
+		MustParseDockerPort("80"):        {},
+		MustParseDockerPort("53/udp"):    {},
+		MustParseDockerPort("8080/tcp"):  {},
+		MustParseDockerPort("10000/udp"): {},
+	}
+
+	data, err := json.Marshal(exposedPorts)
+	if err != nil {
+		t.Fatalf("Failed to marshal ports: %v", err)
+	}
+	if string(data) != `{"10000/udp":{},"53/udp":{},"80/tcp":{},"8080/tcp":{}}` {
+		t.Fatalf("Unexpected ExposedPorts JSON %s", string(data))
+	}
+
+	var decoded map[DockerPort]struct{}
+	if err := json.Unmarshal([]byte(`{"80/tcp":{},"53/udp":{},"443":{}}`), &decoded); err != nil {
+		t.Fatalf("Failed to unmarshal ports: %v", err)
+	}
+	for _, port := range []string{"80/tcp", "53/udp", "443/tcp"} {
+		if _, ok := decoded[MustParseDockerPort(port)]; !ok {
+			t.Fatalf("Expected port %s in %v", port, decoded)
+		}
+	}
+
+	if err := json.Unmarshal([]byte(`{"80/icmp":{}}`), &decoded); err == nil {
+		t.Fatalf("Expected error for invalid port key")
+	}
+}
+
+func TestDockerPort_UnmarshalText(t *testing.T) {
+	for _, data := range validPorts {
+		var port DockerPort
+		if err := port.UnmarshalText([]byte(data.Input)); err != nil {
+			t.Fatalf("Expected no error for input: %v\nBut got: %#v", data.Input, err)
+		}
+		if text, _ := port.MarshalText(); string(text) != data.Input {
+			t.Fatalf("Expected '%s' but got '%s'", data.Input, string(text))
+		}
+	}
+
+	for _, data := range invalidPorts {
+		var port DockerPort
+		if err := port.UnmarshalText([]byte(data.Input)); err == nil {
+			t.Fatalf("Expected error for input: %v\nBut got: %#v", data.Input, port)
+		}
+	}
+}
+
+var dockerPortYAMLInput = []struct {
+	Input    string
+	Port     string
+	Protocol string
+}{
+	{"port: 80\n", "80", ProtocolTCP},               // port as int
+	{"port: \"8080\"\n", "8080", ProtocolTCP},       // port as string
+	{"port: '8080'\n", "8080", ProtocolTCP},         // port as single quoted string
+	{"port: 10000/udp\n", "10000", ProtocolUDP},     // port and protocol in docker notation
+	{"port: \"10000/udp\"\n", "10000", ProtocolUDP}, // quoted docker notation
+}
+
+func TestDockerPort_YAMLRoundTrip(t *testing.T) {
+	for _, data := range dockerPortYAMLInput {
+		var config struct {
+			Port DockerPort `yaml:"port"`
+		}
+		if err := yaml.Unmarshal([]byte(data.Input), &config); err != nil {
+			t.Fatalf("Expected no error for input: %v\nBut got: %#v", data.Input, err)
+		}
+		if config.Port.Port != data.Port || config.Port.Protocol != data.Protocol {
+			t.Fatalf("Expected %s/%s but got %s for input: %v", data.Port, data.Protocol, config.Port, data.Input)
+		}
+
+		output, err := yaml.Marshal(config)
+		if err != nil {
+			t.Fatalf("Failed to marshal %v: %v", config.Port, err)
+		}
+
+		// Quoting style may change, but the value must not change its type.
+		var expected, actual map[string]interface{}
+		if err := yaml.Unmarshal([]byte(data.Input), &expected); err != nil {
+			t.Fatalf("Failed to unmarshal input: %v", err)
+		}
+		if err := yaml.Unmarshal(output, &actual); err != nil {
+			t.Fatalf("Failed to unmarshal output: %v", err)
+		}
+		if expected["port"] != actual["port"] {
+			t.Fatalf("Expected %#v but got %#v after round trip of: %v", expected["port"], actual["port"], data.Input)
+		}
+	}
+}
+
+var invalidDockerPortYAMLInput = []struct {
+	Input  string
+	Prefix string
+}{
+	{"port: 0\n", "Line 1, column 7: "},
+	{"name: test\nport: 80/icmp\n", "Line 2, column 7: "},
+	{"port:\n  - 80\n", "Line 2, column 3: "},
+	{"port: true\n", "Line 1, column 7: "},
+}
+
+func TestDockerPort_InvalidYAMLInput(t *testing.T) {
+	for _, data := range invalidDockerPortYAMLInput {
+		var config struct {
+			Name string     `yaml:"name"`
+			Port DockerPort `yaml:"port"`
+		}
+		err := yaml.Unmarshal([]byte(data.Input), &config)
+		if err == nil {
+			t.Fatalf("Expected error for input: %v\nBut got: %#v", data.Input, config.Port)
+		}
+		if !strings.Contains(err.Error(), data.Prefix) {
+			t.Fatalf("Expected error to contain '%s', got '%v'", data.Prefix, err)
+		}
+	}
+}
+
+func TestDockerPort_YAMLSequence(t *testing.T) {
+	input := "ports:\n- 80\n- \"443\"\n- 53/udp\n"
+
+	var config struct {
+		Ports []DockerPort `yaml:"ports"`
+	}
+	if err := yaml.Unmarshal([]byte(input), &config); err != nil {
+		t.Fatalf("Failed to unmarshal ports: %v", err)
+	}
+	output, err := yaml.Marshal(config)
+	if err != nil {
+		t.Fatalf("Failed to marshal ports: %v", err)
+	}
+	if string(output) != "ports:\n    - 80\n    - \"443\"\n    - 53/udp\n" {
+		t.Fatalf("Unexpected YAML:\n%s", string(output))
+	}
+}
+
+func TestDockerPort_Format(t *testing.T) {
+	var formats = []struct {
+		Input  string
+		Format PortFormat
+	}{
+		{"80/tcp", PortFormatDocker},
+		{"80", PortFormatString},
+		{"53/udp", PortFormatDocker},
+	}
+	for _, data := range formats {
+		if format := MustParseDockerPort(data.Input).Format(); format != data.Format {
+			t.Fatalf("Expected format %s for '%s', got %s", data.Format, data.Input, format)
+		}
+	}
+
+	var port DockerPort
+	if err := json.Unmarshal([]byte("80"), &port); err != nil || port.Format() != PortFormatNumber {
+		t.Fatalf("Expected number format, got %s, %v", port.Format(), err)
+	}
+	if (DockerPort{Port: "80", Protocol: ProtocolTCP}).Format() != PortFormatDocker {
+		t.Fatalf("Expected ports built from scratch to use the docker format")
+	}
+}
+
+func TestDockerPort_WithFormat(t *testing.T) {
+	port := DockerPort{Port: "6379", Protocol: ProtocolTCP}
+
+	var formats = []struct {
+		Format   PortFormat
+		Expected string
+	}{
+		{PortFormatDocker, `"6379/tcp"`},
+		{PortFormatNumber, `6379`},
+		{PortFormatString, `"6379"`},
+	}
+	for _, data := range formats {
+		formatted, err := port.WithFormat(data.Format)
+		if err != nil {
+			t.Fatalf("Expected no error for format %s, got %v", data.Format, err)
+		}
+		output, err := json.Marshal(formatted)
+		if err != nil {
+			t.Fatalf("Expected no error marshalling format %s, got %v", data.Format, err)
+		}
+		if string(output) != data.Expected {
+			t.Fatalf("Expected %s for format %s, got %s", data.Expected, data.Format, string(output))
+		}
+		if !formatted.Equals(port) {
+			t.Fatalf("Expected formatted port to equal %v", port)
+		}
+	}
+
+	udp := MustParseDockerPort("53/udp")
+	for _, format := range []PortFormat{PortFormatNumber, PortFormatString, PortFormat(42)} {
+		if _, err := udp.WithFormat(format); !errors.Is(err, ErrUnsupportedPortFormat) {
+			t.Fatalf("Expected ErrUnsupportedPortFormat for format %s, got %v", format, err)
+		}
+	}
+}
+
+var validDockerPortJsonEdgeCases = []struct {
+	Input    string
+	Expected string // the port after decoding into 1/udp
+	Format   PortFormat
+}{
+	{`null`, "1/udp", PortFormatDocker},
+	{` 80 `, "80/tcp", PortFormatNumber},
+	{"\t\"80/udp\"\n", "80/udp", PortFormatDocker},
+}
+
+var invalidDockerPortJsonEdgeCases = []struct {
+	Input    string
+	Expected error
+}{
+	{``, ErrInvalidPortFormat},
+	{`"`, ErrInvalidPortFormat},
+	{`80 81`, ErrInvalidPortFormat},
+	{`"80"x`, ErrInvalidPortFormat},
+	{`true`, ErrInvalidPortFormat},
+	{`false`, ErrInvalidPortFormat},
+	{`{}`, ErrInvalidPortFormat},
+	{`[80]`, ErrInvalidPortFormat},
+	{`80.0`, ErrInvalidPortNumber},
+	{`8e1`, ErrInvalidPortNumber},
+	{`-80`, ErrInvalidPortNumber},
+	{`0`, ErrInvalidPortNumber},
+	{`65536`, ErrInvalidPortNumber},
+	{`"80/icmp"`, ErrInvalidProtocol},
+}
+
+func TestDockerPort_UnmarshalJSONEdgeCases(t *testing.T) {
+	for _, data := range validDockerPortJsonEdgeCases {
+		port := MustParseDockerPort("1/udp")
+		if err := port.UnmarshalJSON([]byte(data.Input)); err != nil {
+			t.Fatalf("Expected no error for input: %q\nBut got: %v", data.Input, err)
+		}
+		if port.String() != data.Expected || port.Format() != data.Format {
+			t.Fatalf("Expected %s in format %s for input: %q\nBut got: %#v", data.Expected, data.Format, data.Input, port)
+		}
+	}
+
+	for _, data := range invalidDockerPortJsonEdgeCases {
+		port := MustParseDockerPort("1/udp")
+		err := port.UnmarshalJSON([]byte(data.Input))
+		if !errors.Is(err, data.Expected) {
+			t.Fatalf("Expected %v for input: %q\nBut got: %v", data.Expected, data.Input, err)
+		}
+		if port != MustParseDockerPort("1/udp") {
+			t.Fatalf("Expected port to be unchanged after error for input: %q", data.Input)
+		}
+	}
+}
+
+func TestDockerPort_MarshalInvalidPorts(t *testing.T) {
+	var ports = []DockerPort{
+		{},
+		{Port: "abc", Protocol: ProtocolTCP, formatJsonMode: PortFormatNumber},
+		{Port: "53", Protocol: ProtocolUDP, formatJsonMode: PortFormatNumber},
+		{Port: "53", Protocol: ProtocolUDP, formatJsonMode: PortFormatString},
+		{Port: "80", Protocol: ProtocolTCP, formatJsonMode: PortFormat(-1)},
+	}
+
+	for _, port := range ports {
+		// Must not panic, errors are fine.
+		json.Marshal(port)
+		yaml.Marshal(port)
+	}
+
+	if _, err := json.Marshal(ports[4]); !errors.Is(err, ErrUnsupportedPortFormat) {
+		t.Fatalf("Expected ErrUnsupportedPortFormat for unknown format, got %v", err)
+	}
+}
+
+// fuzzDockerPortJsonSeeds are inputs that broke decoding or round trips in
+// the past, in addition to the inputs of the tables above.
+var fuzzDockerPortJsonSeeds = []string{
+	``,
+	`t`,
+	`0x50`,
+	`"\u0038\u0030"`,
+	`1e+02`,
+	`"80/tcp""`,
+	`00080`,
+	`{"Port":"80"}`,
+}
+
+// FuzzDockerPort_JSON checks that decoding never panics and that every
+// decoded port survives a round trip.
+func FuzzDockerPort_JSON(f *testing.F) {
+	for _, data := range validDockerPortJsonInput {
+		f.Add([]byte(data.Input))
+	}
+	for _, data := range validDockerPortJsonEdgeCases {
+		f.Add([]byte(data.Input))
+	}
+	for _, data := range invalidDockerPortJsonEdgeCases {
+		f.Add([]byte(data.Input))
+	}
+	for _, input := range fuzzDockerPortJsonSeeds {
+		f.Add([]byte(input))
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var port DockerPort
+		if err := port.UnmarshalJSON(data); err != nil || port.Empty() {
+			return
+		}
+
+		output, err := json.Marshal(port)
+		if err != nil {
+			t.Fatalf("Failed to marshal %#v decoded from %q: %v", port, data, err)
+		}
+		var decoded DockerPort
+		if err := json.Unmarshal(output, &decoded); err != nil {
+			t.Fatalf("Failed to decode %s: %v", output, err)
+		}
+		if decoded != port {
+			t.Fatalf("Expected %#v after round trip of %q, got %#v", port, data, decoded)
+		}
+	})
+}
+
+var canonicalPorts = []struct {
+	Input    string
+	Expected string
+}{
+	{"80", "80/tcp"},
+	{"0080", "80/tcp"},
+	{"00000000000000000443/udp", "443/udp"},
+	{"65535/tcp", "65535/tcp"},
+}
+
+func TestDockerPort_Canonical(t *testing.T) {
+	for _, data := range canonicalPorts {
+		port, err := ParseDockerPort(data.Input)
+		if err != nil {
+			t.Fatalf("Expected no error for input: %v\nBut got: %v", data.Input, err)
+		}
+		if port.String() != data.Expected {
+			t.Fatalf("Expected '%s' for input '%s', got '%s'", data.Expected, data.Input, port.String())
+		}
+	}
+
+	for _, input := range []string{"+80", "-80", " 80", "8 0", "0x50", "1_000", "00000", "65536"} {
+		if _, err := ParseDockerPort(input); !errors.Is(err, ErrInvalidPortNumber) {
+			t.Fatalf("Expected ErrInvalidPortNumber for '%s', got %v", input, err)
+		}
+	}
+
+	var port DockerPort
+	if err := json.Unmarshal([]byte(`"0080"`), &port); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if output, _ := json.Marshal(port); string(output) != `"80"` {
+		t.Fatalf("Expected canonical port, got %s", output)
+	}
+
+	if err := (DockerPort{Port: "0080", Protocol: ProtocolTCP}).Validate(); !errors.Is(err, ErrInvalidPortNumber) {
+		t.Fatalf("Expected error for leading zeros, got %v", err)
+	}
+}
+
+var portNumbers = []struct {
+	Port     DockerPort
+	Expected uint16
+}{
+	{MustParseDockerPort("80"), 80},
+	{MustParseDockerPort("65535/udp"), 65535},
+	{DockerPort{}, 0},
+	{DockerPort{Port: "abc"}, 0},
+	{DockerPort{Port: "-1"}, 0},
+	{DockerPort{Port: "70000"}, 0},
+}
+
+func TestDockerPort_Number(t *testing.T) {
+	for _, data := range portNumbers {
+		if number := data.Port.Number(); number != data.Expected {
+			t.Fatalf("Expected %d for %#v, got %d", data.Expected, data.Port, number)
+		}
+	}
+}
+
+func TestNewDockerPort(t *testing.T) {
+	port, err := NewDockerPort(80, ProtocolTCP)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if port != MustParseDockerPort("80/tcp") || port.Number() != 80 {
+		t.Fatalf("Unexpected port %#v", port)
+	}
+	if MustNewDockerPort(53, ProtocolUDP).String() != "53/udp" {
+		t.Fatalf("Unexpected port %v", MustNewDockerPort(53, ProtocolUDP))
+	}
+
+	if _, err := NewDockerPort(0, ProtocolTCP); !errors.Is(err, ErrInvalidPortNumber) {
+		t.Fatalf("Expected ErrInvalidPortNumber for port 0, got %v", err)
+	}
+	for _, protocol := range []string{"", "icmp", "tcp/udp", "TCP"} {
+		if _, err := NewDockerPort(80, protocol); !errors.Is(err, ErrInvalidPort) {
+			t.Fatalf("Expected error for protocol '%s', got %v", protocol, err)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package generictypes
+
+import (
+	"github.com/juju/errgo"
+
+	"bytes"
+	"encoding/json"
+	"net"
+	"sort"
+	"strings"
+)
+
+var (
+	ErrInvalidPortBinding = errgo.New("Invalid port binding")
+)
+
+// PortBinding is a host address a container port is published on, like in
+// the PortBindings object of the Docker Engine API.
+type PortBinding struct {
+	// HostIP is the IP the port is published on, empty for all IPs.
+	HostIP string `json:"HostIp"`
+
+	// HostPort is the published port, a range like "8000-8010" or empty for a
+	// random port.
+	HostPort string `json:"HostPort"`
+}
+
+// Validate checks that HostIP is empty or an IP and HostPort is empty, a port
+// number or a range of port numbers.
+func (b PortBinding) Validate() error {
+	if b.HostIP != "" && net.ParseIP(b.HostIP) == nil {
+		return newPortError(b.HostIP, ErrInvalidPortBinding, "Invalid host IP %#v", b.HostIP)
+	}
+	if b.HostPort == "" {
+		return nil
+	}
+	if _, err := parseComposePortRange(b.HostPort); err != nil || strings.Contains(b.HostPort, "/") {
+		return newPortError(b.HostPort, ErrInvalidPortBinding, "Invalid host port %#v", b.HostPort)
+	}
+	return nil
+}
+
+// PortBindings maps container ports to the host addresses they are published
+// on. It marshals to JSON like the PortBindings object of the Docker Engine
+// API, e.g. {"80/tcp": [{"HostIp": "0.0.0.0", "HostPort": "8080"}]}.
+//
+// Use Add and Get instead of indexing the map directly, so that ports like
+// "80" and "80/tcp" are the same key.
+type PortBindings map[DockerPort][]PortBinding
+
+// ParsePortBindings parses port mappings in `docker run -p` syntax, e.g.
+// "8080:80", "127.0.0.1:53:53/udp", "[::1]::80" or "8000-8001:80-81".
+func ParsePortBindings(specs ...string) (PortBindings, error) {
+	bindings := PortBindings{}
+	for _, input := range specs {
+		parsed, err := parsePortSpec(input)
+		if err != nil {
+			return nil, notef(err, "Invalid port mapping %s", input)
+		}
+		for _, spec := range parsed {
+			binding := PortBinding{HostIP: spec.HostIP, HostPort: spec.HostPort}
+			if port, err := ParseDockerPort(spec.HostPort); err == nil {
+				binding.HostPort = port.Port // drop leading zeros
+			}
+			bindings.Add(spec.ContainerPort, binding)
+		}
+	}
+	return bindings, nil
+}
+
+// Add adds a binding for the given container port.
+func (b PortBindings) Add(port DockerPort, binding PortBinding) {
+	key := portSetKey(port)
+	b[key] = append(b[key], binding)
+}
+
+// Get returns the bindings of the given container port. Bindings of keys that
+// only differ in their format, e.g. set by indexing the map directly, are
+// merged ordered by the format of the key.
+func (b PortBindings) Get(port DockerPort) []PortBinding {
+	var keys []DockerPort
+	for key := range b {
+		if key.Equals(port) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Port != keys[j].Port {
+			return keys[i].Port < keys[j].Port
+		}
+		if keys[i].Format() != keys[j].Format() {
+			return keys[i].Format() < keys[j].Format()
+		}
+		return keys[i].ServiceName() < keys[j].ServiceName()
+	})
+
+	var bindings []PortBinding
+	for _, key := range keys {
+		if b[key] != nil && bindings == nil {
+			bindings = []PortBinding{}
+		}
+		bindings = append(bindings, b[key]...)
+	}
+	return bindings
+}
+
+// Ports returns the bound container ports ordered by number, then protocol.
+func (b PortBindings) Ports() []DockerPort {
+	var set PortSet
+	for port := range b {
+		set.Add(port)
+	}
+	return set.Ports()
+}
+
+// Validate checks all container ports and bindings.
+func (b PortBindings) Validate() error {
+	for _, port := range b.sortedKeys() {
+		if err := port.Validate(); err != nil {
+			return err
+		}
+		for _, binding := range b[port] {
+			if err := binding.Validate(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// MarshalJSON encodes the bindings ordered like Ports(). Bindings of keys that
+// only differ in their format, e.g. "80" and "80/tcp", are merged.
+func (b PortBindings) MarshalJSON() ([]byte, error) {
+	if b == nil {
+		return []byte("null"), nil
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, port := range b.Ports() {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(port.String())
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(b.Get(port))
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// UnmarshalJSON decodes a PortBindings object and validates all ports and
+// bindings. A port must not be given twice, e.g. as "80" and "80/tcp".
+func (b *PortBindings) UnmarshalJSON(data []byte) error {
+	var object map[string][]PortBinding
+	if err := json.Unmarshal(data, &object); err != nil {
+		return err
+	}
+	if object == nil {
+		*b = nil
+		return nil
+	}
+
+	// Sort the keys, so that the same error is returned for every call.
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	parsed := PortBindings{}
+	for _, key := range keys {
+		bindings := object[key]
+		port, err := ParseDockerPort(key)
+		if err != nil {
+			return err
+		}
+		if _, ok := parsed[portSetKey(port)]; ok {
+			return newPortError(key, ErrDuplicatePort, "Duplicate port %s", port.String())
+		}
+		for _, binding := range bindings {
+			if err := binding.Validate(); err != nil {
+				return err
+			}
+		}
+		parsed[portSetKey(port)] = bindings
+	}
+	*b = parsed
+	return nil
+}
+
+// sortedKeys returns the map keys ordered by number, then protocol.
+func (b PortBindings) sortedKeys() []DockerPort {
+	keys := make([]DockerPort, 0, len(b))
+	for port := range b {
+		keys = append(keys, port)
+	}
+	sortPorts(keys)
+	return keys
+}
@@ -0,0 +1,143 @@
+package generictypes
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+var portBindingSpecs = []struct {
+	Specs    []string
+	Expected string
+}{
+	{[]string{"8080:80"}, `{"80/tcp":[{"HostIp":"","HostPort":"8080"}]}`},
+	{[]string{"80"}, `{"80/tcp":[{"HostIp":"","HostPort":""}]}`},
+	{[]string{"0.0.0.0:8080:80", "127.0.0.1:8081:80"}, `{"80/tcp":[{"HostIp":"0.0.0.0","HostPort":"8080"},{"HostIp":"127.0.0.1","HostPort":"8081"}]}`},
+	{[]string{"127.0.0.1::53/udp"}, `{"53/udp":[{"HostIp":"127.0.0.1","HostPort":""}]}`},
+	{[]string{"[::1]:8080:80"}, `{"80/tcp":[{"HostIp":"::1","HostPort":"8080"}]}`},
+	{[]string{"8000-8001:80-81"}, `{"80/tcp":[{"HostIp":"","HostPort":"8000"}],"81/tcp":[{"HostIp":"","HostPort":"8001"}]}`},
+	{[]string{"8000-8010:80"}, `{"80/tcp":[{"HostIp":"","HostPort":"8000-8010"}]}`},
+	{[]string{"08080:0080", "443:443"}, `{"80/tcp":[{"HostIp":"","HostPort":"8080"}],"443/tcp":[{"HostIp":"","HostPort":"443"}]}`},
+}
+
+func TestParsePortBindings(t *testing.T) {
+	for _, data := range portBindingSpecs {
+		bindings, err := ParsePortBindings(data.Specs...)
+		if err != nil {
+			t.Fatalf("Expected no error for %v, got %v", data.Specs, err)
+		}
+		if err := bindings.Validate(); err != nil {
+			t.Fatalf("Expected valid bindings for %v, got %v", data.Specs, err)
+		}
+		output, err := json.Marshal(bindings)
+		if err != nil {
+			t.Fatalf("Expected no error for %v, got %v", data.Specs, err)
+		}
+		if string(output) != data.Expected {
+			t.Fatalf("Expected %s for %v, got %s", data.Expected, data.Specs, output)
+		}
+	}
+}
+
+func TestParsePortBindingsErrors(t *testing.T) {
+	var specs = []struct {
+		Spec     string
+		Expected error
+	}{
+		{"", ErrInvalidPortNumber},
+		{"a:80", ErrInvalidPortNumber},
+		{"8080:80/icmp", ErrInvalidProtocol},
+		{"300.0.0.1:8080:80", ErrInvalidPortBinding},
+		{"::1:8080:80", ErrInvalidPortFormat},
+		{"[::1:8080:80", ErrInvalidPortBinding},
+		{"8080:70000", ErrInvalidPortNumber},
+		{"8000-8005:80-81", ErrInvalidPortBinding},
+		{"8000:80-81", ErrInvalidPortBinding},
+	}
+	for _, data := range specs {
+		if bindings, err := ParsePortBindings(data.Spec); !errors.Is(err, data.Expected) {
+			t.Fatalf("Expected %v for '%s', got %v, %v", data.Expected, data.Spec, bindings, err)
+		}
+	}
+}
+
+func TestPortBindings_JSON(t *testing.T) {
+	input := `{"53/udp":[{"HostIp":"","HostPort":"53"}],"80/tcp":[{"HostIp":"0.0.0.0","HostPort":"8080"}],"443":[]}`
+
+	var bindings PortBindings
+	if err := json.Unmarshal([]byte(input), &bindings); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	expected := []PortBinding{{HostIP: "0.0.0.0", HostPort: "8080"}}
+	if got := bindings.Get(MustParseDockerPort("80")); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("Expected %v, got %v", expected, got)
+	}
+
+	output, err := json.Marshal(bindings)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if string(output) != `{"53/udp":[{"HostIp":"","HostPort":"53"}],"80/tcp":[{"HostIp":"0.0.0.0","HostPort":"8080"}],"443/tcp":[]}` {
+		t.Fatalf("Unexpected JSON %s", output)
+	}
+
+	var invalid = []struct {
+		Input    string
+		Expected error
+	}{
+		{`{"80":[],"80/tcp":[]}`, ErrDuplicatePort},
+		{`{"80/icmp":[]}`, ErrInvalidProtocol},
+		{`{"80/tcp":[{"HostIp":"localhost","HostPort":"8080"}]}`, ErrInvalidPortBinding},
+		{`{"80/tcp":[{"HostIp":"","HostPort":"http"}]}`, ErrInvalidPortBinding},
+		{`{"80/tcp":[{"HostIp":"","HostPort":"8080/tcp"}]}`, ErrInvalidPortBinding},
+	}
+	for _, data := range invalid {
+		var bindings PortBindings
+		if err := json.Unmarshal([]byte(data.Input), &bindings); !errors.Is(err, data.Expected) {
+			t.Fatalf("Expected %v for %s, got %v", data.Expected, data.Input, err)
+		}
+	}
+
+	// With several invalid ports, the error of the first key is returned.
+	for i := 0; i < 20; i++ {
+		var bindings PortBindings
+		err := json.Unmarshal([]byte(`{"90/icmp":[],"80/tcp":[],"80":[],"70/tcp":[{"HostIp":"localhost","HostPort":""}]}`), &bindings)
+		var portErr *PortError
+		if !errors.As(err, &portErr) || portErr.Input != "localhost" {
+			t.Fatalf("Expected the error of 70/tcp, got %v", err)
+		}
+
+		err = json.Unmarshal([]byte(`{"80/tcp":[],"80":[]}`), &bindings)
+		if !errors.As(err, &portErr) || portErr.Input != "80/tcp" {
+			t.Fatalf("Expected the duplicate 80/tcp, got %v", err)
+		}
+	}
+}
+
+func TestPortBindings_MergesFormats(t *testing.T) {
+	bindings := PortBindings{}
+	bindings.Add(MustParseDockerPort("80"), PortBinding{HostPort: "8080"})
+	bindings.Add(MustParseDockerPort("80/tcp"), PortBinding{HostPort: "8081"})
+
+	// Keys set directly may differ in their format only.
+	bindings[MustParseDockerPort("80")] = append(bindings[MustParseDockerPort("80")], PortBinding{HostPort: "8082"})
+
+	bindings.Add(MustParseDockerPort("80/udp"), PortBinding{HostPort: "8083"})
+
+	if len(bindings.Get(MustParseDockerPort("80/tcp"))) != 3 {
+		t.Fatalf("Expected 3 bindings, got %v", bindings.Get(MustParseDockerPort("80/tcp")))
+	}
+
+	// Merged bindings are ordered by the format of their key.
+	expected := `{"80/tcp":[{"HostIp":"","HostPort":"8080"},{"HostIp":"","HostPort":"8081"},{"HostIp":"","HostPort":"8082"}],"80/udp":[{"HostIp":"","HostPort":"8083"}]}`
+	for i := 0; i < 20; i++ {
+		output, err := json.Marshal(bindings)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if string(output) != expected {
+			t.Fatalf("Expected %s, got %s", expected, output)
+		}
+	}
+}
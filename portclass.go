@@ -0,0 +1,240 @@
+package generictypes
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// PortClass is the IANA range a port number belongs to, see RFC 6335.
+type PortClass int
+
+const (
+	PortClassUnknown    PortClass = 0 // Invalid port numbers
+	PortClassSystem     PortClass = 1 // 1-1023, also called well-known ports
+	PortClassRegistered PortClass = 2 // 1024-49151, also called user ports
+	PortClassDynamic    PortClass = 3 // 49152-65535, also called private ports
+)
+
+func (c PortClass) String() string {
+	switch c {
+	case PortClassUnknown:
+		return "unknown"
+	case PortClassSystem:
+		return "system"
+	case PortClassRegistered:
+		return "registered"
+	case PortClassDynamic:
+		return "dynamic"
+	default:
+		return fmt.Sprintf("PortClass(%d)", int(c))
+	}
+}
+
+var (
+	// IANAEphemeralRange is the dynamic port range of RFC 6335, which is
+	// used for ephemeral ports by e.g. Windows and FreeBSD.
+	IANAEphemeralRange = PortRange{First: 49152, Last: 65535}
+
+	// LinuxEphemeralRange is the default of ip_local_port_range on Linux.
+	LinuxEphemeralRange = PortRange{First: 32768, Last: 60999}
+)
+
+// LinuxEphemeralRangePath is the file the ephemeral port range of the local
+// Linux host is read from.
+const LinuxEphemeralRangePath = "/proc/sys/net/ipv4/ip_local_port_range"
+
+// ReadEphemeralRange reads an ephemeral port range in the format of
+// ip_local_port_range, i.e. the first and last port separated by whitespace,
+// e.g. from LinuxEphemeralRangePath.
+func ReadEphemeralRange(path string) (PortRange, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return PortRange{}, maskAny(err)
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) == 2 {
+		if r, err := ParsePortRange(fields[0] + "-" + fields[1]); err == nil {
+			return r, nil
+		}
+	}
+	return PortRange{}, newPortError(string(data), ErrInvalidPortRange, "Invalid port range in %s: %#v", path, string(data))
+}
+
+// Class returns the IANA range of the port, or PortClassUnknown if the port
+// number is invalid.
+func (d DockerPort) Class() PortClass {
+	switch number := d.Number(); {
+	case number == 0:
+		return PortClassUnknown
+	case number < 1024:
+		return PortClassSystem
+	case number < 49152:
+		return PortClassRegistered
+	default:
+		return PortClassDynamic
+	}
+}
+
+// IsPrivileged returns true for ports below 1024, which only root or
+// processes with CAP_NET_BIND_SERVICE can bind on Linux.
+func (d DockerPort) IsPrivileged() bool {
+	return d.Class() == PortClassSystem
+}
+
+// IsPrivileged returns true if the host port, or the first port of a host
+// port range, is below 1024, so that publishing the container port requires
+// root or CAP_NET_BIND_SERVICE on Linux. Random host ports are not privileged.
+func (b PortBinding) IsPrivileged() bool {
+	if b.HostPort == "" || strings.Contains(b.HostPort, "/") {
+		return false
+	}
+	ports, err := parseComposePortRange(b.HostPort)
+	return err == nil && ports[0].IsPrivileged()
+}
+
+// IsEphemeral returns true if the port is in the given ephemeral range, e.g.
+// LinuxEphemeralRange or a range read with ReadEphemeralRange. Binding such a
+// port on the host may fail when the kernel uses it for outgoing connections.
+func (d DockerPort) IsEphemeral(ephemeral PortRange) bool {
+	number := d.Number()
+	return number != 0 && ephemeral.Contains(number)
+}
+
+// PortDenyRule denies a range of ports for a reason.
+type PortDenyRule struct {
+	// Ports are the denied port numbers.
+	Ports PortRange `json:"Ports"`
+
+	// Protocol is "tcp" or "udp", or empty for both.
+	Protocol string `json:"Protocol,omitempty"`
+
+	// Reason explains why the ports are denied.
+	Reason string `json:"Reason"`
+}
+
+// Matches returns true if the rule denies the given port.
+func (r PortDenyRule) Matches(port DockerPort) bool {
+	if r.Protocol != "" && r.Protocol != port.Protocol {
+		return false
+	}
+	number := port.Number()
+	return number != 0 && r.Ports.Contains(number)
+}
+
+// Validate checks the range and protocol of the rule.
+func (r PortDenyRule) Validate() error {
+	if err := r.Ports.Validate(); err != nil {
+		return err
+	}
+	switch r.Protocol {
+	case "", ProtocolTCP, ProtocolUDP:
+		return nil
+	default:
+		return newPortError(r.Protocol, ErrInvalidProtocol, "Unknown protocol: '%s'", r.Protocol)
+	}
+}
+
+// PortViolation is a port denied by a PortDenyRule.
+type PortViolation struct {
+	Port DockerPort
+	Rule PortDenyRule
+}
+
+// Returns the port and the reason, e.g. "22/tcp: Remote shell access (SSH)".
+func (v PortViolation) String() string {
+	return fmt.Sprintf("%s: %s", v.Port.String(), v.Rule.Reason)
+}
+
+// PortDenyList is a list of rules for sensitive ports, e.g. to warn when
+// tenants expose them.
+type PortDenyList []PortDenyRule
+
+// DefaultPortDenyList denies ports of remote access and infrastructure
+// services that should not be exposed.
+var DefaultPortDenyList = PortDenyList{
+	{Ports: PortRange{22, 22}, Protocol: ProtocolTCP, Reason: "Remote shell access (SSH)"},
+	{Ports: PortRange{23, 23}, Protocol: ProtocolTCP, Reason: "Unencrypted remote shell access (Telnet)"},
+	{Ports: PortRange{111, 111}, Reason: "RPC port mapper, commonly abused for amplification attacks"},
+	{Ports: PortRange{135, 139}, Reason: "Windows RPC and NetBIOS"},
+	{Ports: PortRange{445, 445}, Protocol: ProtocolTCP, Reason: "Windows file sharing (SMB)"},
+	{Ports: PortRange{2375, 2376}, Protocol: ProtocolTCP, Reason: "Docker daemon API, grants root access to the host"},
+	{Ports: PortRange{2379, 2380}, Protocol: ProtocolTCP, Reason: "etcd, stores cluster state and secrets"},
+	{Ports: PortRange{3389, 3389}, Reason: "Remote desktop access (RDP)"},
+	{Ports: PortRange{5900, 5900}, Protocol: ProtocolTCP, Reason: "Remote desktop access (VNC)"},
+	{Ports: PortRange{6443, 6443}, Protocol: ProtocolTCP, Reason: "Kubernetes API server"},
+	{Ports: PortRange{10250, 10250}, Protocol: ProtocolTCP, Reason: "Kubelet API, allows running commands in containers"},
+}
+
+// PrivilegedPortRule denies ports below 1024. It is not part of
+// DefaultPortDenyList, since only binding such a port on the host requires
+// privileges, not listening on it in a container. Use it with
+// EvaluateBindings, e.g. PortDenyList{PrivilegedPortRule}.EvaluateBindings(b).
+var PrivilegedPortRule = PortDenyRule{Ports: PortRange{1, 1023}, Reason: "Privileged port, binding it requires root or CAP_NET_BIND_SERVICE"}
+
+// Validate checks all rules of the list.
+func (l PortDenyList) Validate() error {
+	for i, rule := range l {
+		if err := rule.Validate(); err != nil {
+			return FieldError{Path: fmt.Sprintf("[%d]", i), Err: err}
+		}
+	}
+	return nil
+}
+
+// Evaluate returns a violation for every rule that denies one of the given
+// ports, ordered by port, then by rule. Ports given more than once, e.g. as
+// "80" and "80/tcp", are only evaluated once.
+func (l PortDenyList) Evaluate(ports ...DockerPort) []PortViolation {
+	var set PortSet
+	for _, port := range ports {
+		set.Add(port)
+	}
+
+	var violations []PortViolation
+	for _, port := range set.Ports() {
+		for _, rule := range l {
+			if rule.Matches(port) {
+				violations = append(violations, PortViolation{Port: port, Rule: rule})
+			}
+		}
+	}
+	return violations
+}
+
+// EvaluateBindings returns a violation for every rule that denies a published
+// container port of the given bindings, ordered by port, then by rule, e.g.
+// for 22/tcp published as "127.0.0.1:2222:22". PrivilegedPortRule is checked
+// against the host ports instead, since only binding those requires
+// privileges. Host port ranges are evaluated port by port with the protocol of
+// the container port, random and invalid host ports are skipped.
+func (l PortDenyList) EvaluateBindings(bindings PortBindings) []PortViolation {
+	var containerPorts, hostPorts PortSet
+	for _, containerPort := range bindings.Ports() {
+		containerPorts.Add(containerPort)
+		for _, binding := range bindings.Get(containerPort) {
+			if binding.HostPort == "" || strings.Contains(binding.HostPort, "/") {
+				continue
+			}
+			if ports, err := parseComposePortRange(binding.HostPort + "/" + containerPort.Protocol); err == nil {
+				for _, port := range ports {
+					hostPorts.Add(port)
+				}
+			}
+		}
+	}
+
+	var violations []PortViolation
+	for _, port := range containerPorts.Union(hostPorts).Ports() {
+		for _, rule := range l {
+			ports := containerPorts
+			if rule == PrivilegedPortRule {
+				ports = hostPorts
+			}
+			if ports.Contains(port) && rule.Matches(port) {
+				violations = append(violations, PortViolation{Port: port, Rule: rule})
+			}
+		}
+	}
+	return violations
+}
@@ -0,0 +1,192 @@
+package generictypes
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/juju/errgo"
+)
+
+var portClasses = []struct {
+	Port       DockerPort
+	Class      PortClass
+	Privileged bool
+	Ephemeral  bool
+}{
+	{MustParseDockerPort("22"), PortClassSystem, true, false},
+	{MustParseDockerPort("1023/udp"), PortClassSystem, true, false},
+	{MustParseDockerPort("1024"), PortClassRegistered, false, false},
+	{MustParseDockerPort("32768"), PortClassRegistered, false, true},
+	{MustParseDockerPort("49151"), PortClassRegistered, false, true},
+	{MustParseDockerPort("49152"), PortClassDynamic, false, true},
+	{MustParseDockerPort("65535"), PortClassDynamic, false, false},
+	{DockerPort{Port: "0", Protocol: "tcp"}, PortClassUnknown, false, false},
+	{DockerPort{Port: "http", Protocol: "tcp"}, PortClassUnknown, false, false},
+}
+
+func TestDockerPort_Class(t *testing.T) {
+	for _, data := range portClasses {
+		if class := data.Port.Class(); class != data.Class {
+			t.Fatalf("Expected %s for %s, got %s", data.Class, data.Port.String(), class)
+		}
+		if data.Port.IsPrivileged() != data.Privileged {
+			t.Fatalf("Expected privileged %v for %s", data.Privileged, data.Port.String())
+		}
+		if data.Port.IsEphemeral(LinuxEphemeralRange) != data.Ephemeral {
+			t.Fatalf("Expected ephemeral %v for %s", data.Ephemeral, data.Port.String())
+		}
+	}
+	if PortClass(7).String() != "PortClass(7)" {
+		t.Fatalf("Expected PortClass(7), got %s", PortClass(7).String())
+	}
+}
+
+func TestReadEphemeralRange(t *testing.T) {
+	var files = []struct {
+		Content  string
+		Expected PortRange
+		Error    bool
+	}{
+		{"32768\t60999\n", PortRange{32768, 60999}, false},
+		{"1024 65535", PortRange{1024, 65535}, false},
+		{"", PortRange{}, true},
+		{"32768", PortRange{}, true},
+		{"60999\t32768\n", PortRange{}, true},
+		{"a b", PortRange{}, true},
+	}
+	for _, data := range files {
+		path := filepath.Join(t.TempDir(), "ip_local_port_range")
+		if err := os.WriteFile(path, []byte(data.Content), 0644); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		r, err := ReadEphemeralRange(path)
+		if data.Error {
+			if !errors.Is(err, ErrInvalidPortRange) {
+				t.Fatalf("Expected ErrInvalidPortRange for %#v, got %v, %v", data.Content, r, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Expected no error for %#v, got %v", data.Content, err)
+		}
+		if r != data.Expected {
+			t.Fatalf("Expected %v for %#v, got %v", data.Expected, data.Content, r)
+		}
+	}
+
+	if _, err := ReadEphemeralRange(filepath.Join(t.TempDir(), "missing")); !os.IsNotExist(errgo.Cause(err)) {
+		t.Fatalf("Expected a not exist error for missing file, got %v", err)
+	}
+}
+
+func TestPortDenyList_Evaluate(t *testing.T) {
+	var evaluations = []struct {
+		Ports    []string
+		Expected []string
+	}{
+		{[]string{"8080", "443/udp", "80"}, nil},
+		{[]string{"2375", "22/tcp", "22"}, []string{
+			"22/tcp: Remote shell access (SSH)",
+			"2375/tcp: Docker daemon API, grants root access to the host",
+		}},
+		{[]string{"22/udp", "3389/udp", "137/udp"}, []string{
+			"137/udp: Windows RPC and NetBIOS",
+			"3389/udp: Remote desktop access (RDP)",
+		}},
+		{[]string{"1024", "30000/udp"}, nil},
+	}
+	for _, data := range evaluations {
+		var ports []DockerPort
+		for _, input := range data.Ports {
+			ports = append(ports, MustParseDockerPort(input))
+		}
+		var violations []string
+		for _, violation := range DefaultPortDenyList.Evaluate(ports...) {
+			violations = append(violations, violation.String())
+		}
+		if !reflect.DeepEqual(violations, data.Expected) {
+			t.Fatalf("Expected %v for %v, got %v", data.Expected, data.Ports, violations)
+		}
+	}
+}
+
+func TestPortDenyList_EvaluateBindings(t *testing.T) {
+	bindings, err := ParsePortBindings("8080:80", "443:443", "127.0.0.1:2222:22", "1022-1024:9000-9002/udp", "[::1]::23")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	var violations []string
+	list := append(PortDenyList{PrivilegedPortRule}, DefaultPortDenyList...)
+	for _, violation := range list.EvaluateBindings(bindings) {
+		violations = append(violations, violation.String())
+	}
+	expected := []string{
+		"22/tcp: Remote shell access (SSH)",
+		"23/tcp: Unencrypted remote shell access (Telnet)",
+		"443/tcp: Privileged port, binding it requires root or CAP_NET_BIND_SERVICE",
+		"1022/udp: Privileged port, binding it requires root or CAP_NET_BIND_SERVICE",
+		"1023/udp: Privileged port, binding it requires root or CAP_NET_BIND_SERVICE",
+	}
+	if !reflect.DeepEqual(violations, expected) {
+		t.Fatalf("Expected %v, got %v", expected, violations)
+	}
+}
+
+func TestPortBinding_IsPrivileged(t *testing.T) {
+	var bindings = []struct {
+		Binding    PortBinding
+		Privileged bool
+	}{
+		{PortBinding{HostPort: "443"}, true},
+		{PortBinding{HostIP: "127.0.0.1", HostPort: "1023"}, true},
+		{PortBinding{HostPort: "1000-1100"}, true},
+		{PortBinding{HostPort: "1024"}, false},
+		{PortBinding{HostPort: ""}, false},
+		{PortBinding{HostPort: "http"}, false},
+		{PortBinding{HostPort: "80/tcp"}, false},
+	}
+	for _, data := range bindings {
+		if data.Binding.IsPrivileged() != data.Privileged {
+			t.Fatalf("Expected privileged %v for %#v", data.Privileged, data.Binding)
+		}
+	}
+}
+
+func TestPortDenyList_JSON(t *testing.T) {
+	if err := DefaultPortDenyList.Validate(); err != nil {
+		t.Fatalf("Expected valid default deny list, got %v", err)
+	}
+
+	var list PortDenyList
+	input := `[{"Ports":"8000-8999","Reason":"Internal services"},{"Ports":"53","Protocol":"udp","Reason":"DNS"}]`
+	if err := json.Unmarshal([]byte(input), &list); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := list.Validate(); err != nil {
+		t.Fatalf("Expected valid deny list, got %v", err)
+	}
+	violations := list.Evaluate(MustParseDockerPort("53/udp"), MustParseDockerPort("53/tcp"), MustParseDockerPort("8080"))
+	if len(violations) != 2 || violations[0].Rule.Reason != "DNS" || violations[1].Port.String() != "8080/tcp" {
+		t.Fatalf("Expected 53/udp and 8080/tcp to be denied, got %v", violations)
+	}
+
+	output, err := json.Marshal(list)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	expected := `[{"Ports":"8000-8999","Reason":"Internal services"},{"Ports":"53-53","Protocol":"udp","Reason":"DNS"}]`
+	if string(output) != expected {
+		t.Fatalf("Expected %s, got %s", expected, output)
+	}
+
+	invalid := PortDenyList{{Ports: PortRange{80, 80}, Reason: "Web"}, {Ports: PortRange{80, 80}, Protocol: "icmp"}}
+	var fieldError FieldError
+	if err := invalid.Validate(); !errors.As(err, &fieldError) || fieldError.Path != "[1]" || !errors.Is(err, ErrInvalidProtocol) {
+		t.Fatalf("Expected ErrInvalidProtocol at [1], got %v", err)
+	}
+}
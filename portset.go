@@ -0,0 +1,209 @@
+package generictypes
+
+import (
+	"github.com/juju/errgo"
+
+	"bytes"
+	"encoding/json"
+	"sort"
+	"strings"
+)
+
+// PortSet is a set of ports. Ports are compared by number and protocol only,
+// so "80" and "80/tcp" are the same port. The zero value is an empty set.
+//
+// PortSet marshals to JSON like the ExposedPorts object of the Docker Engine
+// API, e.g. {"80/tcp": {}, "53/udp": {}}.
+type PortSet struct {
+	ports map[DockerPort]struct{}
+}
+
+// NewPortSet returns a set of the given ports. An error is returned if a port
+// is invalid or given twice, e.g. as "80" and "80/tcp".
+func NewPortSet(ports ...DockerPort) (PortSet, error) {
+	var s PortSet
+	for _, port := range ports {
+		if err := port.Validate(); err != nil {
+			return PortSet{}, err
+		}
+		if !s.Add(port) {
+			return PortSet{}, newPortError(port.String(), ErrDuplicatePort, "Duplicate port %s", port.String())
+		}
+	}
+	return s, nil
+}
+
+// ParsePortSet parses ports like "80", "80/tcp" and "53/udp" into a set. An
+// error is returned if a port is invalid or given twice.
+func ParsePortSet(ports ...string) (PortSet, error) {
+	parsed := make([]DockerPort, 0, len(ports))
+	for _, input := range ports {
+		port, err := ParseDockerPort(input)
+		if err != nil {
+			return PortSet{}, err
+		}
+		parsed = append(parsed, port)
+	}
+	return NewPortSet(parsed...)
+}
+
+// portSetKey returns the port without its format and service name, so that
+// "80", "80/tcp" and "http" are the same key.
+func portSetKey(port DockerPort) DockerPort {
+	return DockerPort{Port: port.Port, Protocol: port.Protocol}
+}
+
+// Add adds the given port and returns false if it was already in the set.
+func (s *PortSet) Add(port DockerPort) bool {
+	key := portSetKey(port)
+	if _, ok := s.ports[key]; ok {
+		return false
+	}
+	if s.ports == nil {
+		s.ports = map[DockerPort]struct{}{}
+	}
+	s.ports[key] = struct{}{}
+	return true
+}
+
+// Remove removes the given port and returns false if it was not in the set.
+func (s *PortSet) Remove(port DockerPort) bool {
+	key := portSetKey(port)
+	if _, ok := s.ports[key]; !ok {
+		return false
+	}
+	delete(s.ports, key)
+	return true
+}
+
+// Contains returns true if the given port is in the set.
+func (s PortSet) Contains(port DockerPort) bool {
+	_, ok := s.ports[portSetKey(port)]
+	return ok
+}
+
+// Len returns the number of ports in the set.
+func (s PortSet) Len() int {
+	return len(s.ports)
+}
+
+// Ports returns the ports of the set ordered by number, then protocol.
+func (s PortSet) Ports() []DockerPort {
+	ports := make([]DockerPort, 0, len(s.ports))
+	for port := range s.ports {
+		ports = append(ports, port)
+	}
+	sortPorts(ports)
+	return ports
+}
+
+// Union returns a new set with the ports of both sets.
+func (s PortSet) Union(other PortSet) PortSet {
+	var result PortSet
+	for port := range s.ports {
+		result.Add(port)
+	}
+	for port := range other.ports {
+		result.Add(port)
+	}
+	return result
+}
+
+// Intersection returns a new set with the ports contained in both sets.
+func (s PortSet) Intersection(other PortSet) PortSet {
+	var result PortSet
+	for port := range s.ports {
+		if other.Contains(port) {
+			result.Add(port)
+		}
+	}
+	return result
+}
+
+// Difference returns a new set with the ports of s that are not in other.
+func (s PortSet) Difference(other PortSet) PortSet {
+	var result PortSet
+	for port := range s.ports {
+		if !other.Contains(port) {
+			result.Add(port)
+		}
+	}
+	return result
+}
+
+// Equals returns true if both sets contain the same ports.
+func (s PortSet) Equals(other PortSet) bool {
+	return s.Len() == other.Len() && s.Difference(other).Len() == 0
+}
+
+// Returns the ports as comma separated list, e.g. "80/tcp,53/udp".
+func (s PortSet) String() string {
+	ports := s.Ports()
+	items := make([]string, len(ports))
+	for i, port := range ports {
+		items[i] = port.String()
+	}
+	return strings.Join(items, ",")
+}
+
+// MarshalJSON encodes the set like ExposedPorts, ordered like Ports().
+func (s PortSet) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, port := range s.Ports() {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(port.String())
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteString(":{}")
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// UnmarshalJSON decodes an ExposedPorts object. The values are ignored, but
+// must be objects. Keys may be short ports like "80", but a port must not be
+// given twice.
+func (s *PortSet) UnmarshalJSON(data []byte) error {
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(data, &object); err != nil {
+		return err
+	}
+	if object == nil {
+		*s = PortSet{}
+		return nil
+	}
+
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if value := bytes.TrimSpace(object[key]); len(value) == 0 || value[0] != '{' {
+			return errgo.Newf("Value of port %#v must be an object, got %s", key, value)
+		}
+	}
+
+	parsed, err := ParsePortSet(keys...)
+	if err != nil {
+		return err
+	}
+	*s = parsed
+	return nil
+}
+
+// sortPorts orders ports by number, then protocol.
+func sortPorts(ports []DockerPort) {
+	sort.Slice(ports, func(i, j int) bool {
+		if a, b := ports[i].Number(), ports[j].Number(); a != b {
+			return a < b
+		}
+		return ports[i].Protocol < ports[j].Protocol
+	})
+}
@@ -0,0 +1,144 @@
+package generictypes
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestPortSet(t *testing.T) {
+	var set PortSet
+	if set.Len() != 0 || set.Contains(MustParseDockerPort("80")) {
+		t.Fatalf("Expected zero value to be empty")
+	}
+
+	if !set.Add(MustParseDockerPort("80")) {
+		t.Fatalf("Expected 80 to be added")
+	}
+	if set.Add(MustParseDockerPort("80/tcp")) {
+		t.Fatalf("Expected 80/tcp to be a duplicate of 80")
+	}
+	if !set.Add(MustParseDockerPort("80/udp")) {
+		t.Fatalf("Expected 80/udp to be added")
+	}
+	if !set.Contains(MustParseDockerPort("80/tcp")) || !set.Contains(MustParseDockerPort("80/udp")) {
+		t.Fatalf("Expected set to contain 80/tcp and 80/udp, got %s", set)
+	}
+
+	if !set.Remove(MustParseDockerPort("80/tcp")) {
+		t.Fatalf("Expected 80/tcp to be removed")
+	}
+	if set.Remove(MustParseDockerPort("80")) {
+		t.Fatalf("Expected 80 to be removed already")
+	}
+	if set.String() != "80/udp" {
+		t.Fatalf("Unexpected set %s", set)
+	}
+}
+
+func TestPortSet_Ordering(t *testing.T) {
+	set, err := ParsePortSet("8080", "53/udp", "443/tcp", "53/tcp", "9/udp", "10000")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if set.String() != "9/udp,53/tcp,53/udp,443/tcp,8080/tcp,10000/tcp" {
+		t.Fatalf("Unexpected order %s", set)
+	}
+}
+
+var portSetOperations = []struct {
+	A, B                            []string
+	Union, Intersection, Difference string
+}{
+	{[]string{"80", "443"}, []string{"443/tcp", "53/udp"}, "53/udp,80/tcp,443/tcp", "443/tcp", "80/tcp"},
+	{[]string{"80"}, nil, "80/tcp", "", "80/tcp"},
+	{nil, []string{"80"}, "80/tcp", "", ""},
+	{[]string{"53/udp"}, []string{"53/tcp"}, "53/tcp,53/udp", "", "53/udp"},
+}
+
+func TestPortSet_Operations(t *testing.T) {
+	for _, data := range portSetOperations {
+		a, err := ParsePortSet(data.A...)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		b, err := ParsePortSet(data.B...)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		if s := a.Union(b).String(); s != data.Union {
+			t.Fatalf("Expected union '%s' of %v and %v, got '%s'", data.Union, data.A, data.B, s)
+		}
+		if s := a.Intersection(b).String(); s != data.Intersection {
+			t.Fatalf("Expected intersection '%s' of %v and %v, got '%s'", data.Intersection, data.A, data.B, s)
+		}
+		if s := a.Difference(b).String(); s != data.Difference {
+			t.Fatalf("Expected difference '%s' of %v and %v, got '%s'", data.Difference, data.A, data.B, s)
+		}
+		if !a.Union(b).Equals(b.Union(a)) {
+			t.Fatalf("Expected union to be commutative")
+		}
+	}
+}
+
+func TestPortSet_Duplicates(t *testing.T) {
+	var duplicates = [][]string{
+		{"80", "80/tcp"},
+		{"0080", "80"},
+		{"53/udp", "53/udp"},
+	}
+	for _, ports := range duplicates {
+		if _, err := ParsePortSet(ports...); !errors.Is(err, ErrDuplicatePort) {
+			t.Fatalf("Expected ErrDuplicatePort for %v, got %v", ports, err)
+		}
+	}
+
+	if _, err := ParsePortSet("80/icmp"); !errors.Is(err, ErrInvalidProtocol) {
+		t.Fatalf("Expected ErrInvalidProtocol, got %v", err)
+	}
+	if _, err := NewPortSet(DockerPort{Port: "0", Protocol: ProtocolTCP}); !errors.Is(err, ErrInvalidPortNumber) {
+		t.Fatalf("Expected ErrInvalidPortNumber, got %v", err)
+	}
+}
+
+func TestPortSet_JSON(t *testing.T) {
+	var config struct {
+		ExposedPorts PortSet
+	}
+	if err := json.Unmarshal([]byte(`{"ExposedPorts":{"8080/tcp":{},"443":{},"53/udp":{}}}`), &config); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if config.ExposedPorts.String() != "53/udp,443/tcp,8080/tcp" {
+		t.Fatalf("Unexpected ports %s", config.ExposedPorts)
+	}
+
+	output, err := json.Marshal(config)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if string(output) != `{"ExposedPorts":{"53/udp":{},"443/tcp":{},"8080/tcp":{}}}` {
+		t.Fatalf("Unexpected JSON %s", output)
+	}
+
+	var empty PortSet
+	if output, _ := json.Marshal(empty); string(output) != `{}` {
+		t.Fatalf("Expected empty object, got %s", output)
+	}
+	if err := json.Unmarshal([]byte(`null`), &empty); err != nil || empty.Len() != 0 {
+		t.Fatalf("Expected empty set for null, got %s, %v", empty, err)
+	}
+
+	var invalid = []string{
+		`{"80":{},"80/tcp":{}}`,
+		`{"80/icmp":{}}`,
+		`{"80":true}`,
+		`["80"]`,
+	}
+	for _, input := range invalid {
+		var set PortSet
+		if err := json.Unmarshal([]byte(input), &set); err == nil {
+			t.Fatalf("Expected error for %s, got %s", input, set)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package generictypes
+
+import (
+	"net"
+	"strings"
+)
+
+// portSpec is the mapping of a single container port to the host.
+type portSpec struct {
+	// HostIP is the IP the port is published on, empty for all IPs.
+	HostIP string
+
+	// HostPort is the published port, a range like "8000-8010" or empty for
+	// a random port.
+	HostPort string
+
+	ContainerPort DockerPort
+}
+
+// parsePortSpec parses a port mapping in `docker run -p` syntax, i.e.
+// "[[<host ip>:][<host port>]:]<container port>[/<protocol>]", e.g.
+// "127.0.0.1:8080:80/udp" or "[::1]::80". Port ranges result in one mapping
+// per port.
+func parsePortSpec(spec string) ([]portSpec, error) {
+	var hostIP, hostPort, containerPort string
+
+	input := spec
+	protocol := ""
+	if i := strings.LastIndex(input, "/"); i >= 0 {
+		input, protocol = input[:i], input[i+1:]
+	}
+
+	// IPv6 host IPs are written in brackets, e.g. "[::1]:8080:80".
+	if strings.HasPrefix(input, "[") {
+		end := strings.Index(input, "]:")
+		if end < 0 {
+			return nil, newPortError(spec, ErrInvalidPortBinding, "Invalid host IP in %#v", spec)
+		}
+		hostIP, input = input[1:end], input[end+2:]
+		parts := strings.SplitN(input, ":", 2)
+		if len(parts) != 2 {
+			return nil, newPortError(spec, ErrInvalidPortFormat, "Invalid format %#v", spec)
+		}
+		hostPort, containerPort = parts[0], parts[1]
+	} else {
+		parts := strings.Split(input, ":")
+		switch len(parts) {
+		case 1:
+			containerPort = parts[0]
+		case 2:
+			hostPort, containerPort = parts[0], parts[1]
+		case 3:
+			hostIP, hostPort, containerPort = parts[0], parts[1], parts[2]
+		default:
+			return nil, newPortError(spec, ErrInvalidPortFormat, "Invalid format %#v", spec)
+		}
+	}
+
+	if protocol != "" {
+		containerPort += "/" + protocol
+	}
+	return expandPortSpec(hostIP, hostPort, containerPort)
+}
+
+// expandPortSpec validates the given host IP and returns one mapping per
+// port of the container port range. Host port ranges of the same length are
+// mapped port by port. Like Docker, a single container port may be published
+// on any port of a host port range, while container port ranges require a
+// host port range of the same length.
+func expandPortSpec(hostIP, hostPort, containerPort string) ([]portSpec, error) {
+	if hostIP != "" && net.ParseIP(hostIP) == nil {
+		return nil, newPortError(hostIP, ErrInvalidPortBinding, "Invalid host IP %#v", hostIP)
+	}
+	ports, err := parseComposePortRange(containerPort)
+	if err != nil {
+		return nil, err
+	}
+
+	var hostPorts []string
+	if hostPort != "" {
+		parsed, err := parseComposePortRange(hostPort)
+		if err != nil {
+			return nil, notef(err, "Invalid published port")
+		}
+		for _, p := range parsed {
+			hostPorts = append(hostPorts, p.Port)
+		}
+		if len(ports) > 1 && len(hostPorts) != len(ports) {
+			return nil, newPortError(hostPort+":"+containerPort, ErrInvalidPortBinding, "Host port range %s does not match container port range %s", hostPort, containerPort)
+		}
+	}
+
+	specs := make([]portSpec, 0, len(ports))
+	for i, port := range ports {
+		spec := portSpec{HostIP: hostIP, HostPort: hostPort, ContainerPort: port}
+		// Ranges of equal length are mapped port by port.
+		if len(ports) > 1 {
+			spec.HostPort = hostPorts[i]
+		}
+		specs = append(specs, spec)
+	}
+	return specs, nil
+}
@@ -0,0 +1,408 @@
+package generictypes
+
+import (
+	"github.com/juju/errgo"
+
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	// DockerHubRegistry is the registry used for images without a registry part.
+	DockerHubRegistry = "registry-1.docker.io"
+
+	// DockerHubLibraryNamespace is the namespace of official Docker Hub images.
+	DockerHubLibraryNamespace = "library"
+)
+
+// manifestMediaTypes are the media types of the manifests a registry can
+// serve for a reference.
+var manifestMediaTypes = []string{
+	MediaTypeOCIIndex,
+	MediaTypeOCIManifest,
+	MediaTypeDockerManifestList,
+	MediaTypeDockerManifest,
+}
+
+var (
+	ErrManifestNotFound   = errgo.New("Manifest not found")
+	ErrRepositoryNotFound = errgo.New("Repository not found")
+	ErrUnauthorized       = errgo.New("Unauthorized")
+	ErrRegistry           = errgo.New("Unexpected registry response")
+)
+
+// RegistryClient talks to registries implementing the OCI Distribution API
+// (https://github.com/opencontainers/distribution-spec). The zero value is
+// ready to use.
+type RegistryClient struct {
+	// HTTPClient is used for all requests. If nil, http.DefaultClient is used.
+	HTTPClient *http.Client
+
+	// PlainHTTP makes the client talk HTTP instead of HTTPS to registries.
+	PlainHTTP bool
+
+	// Credentials is used to authenticate against registries. If nil, all
+	// requests are anonymous.
+	Credentials *CredentialResolver
+}
+
+// ResolveDigest returns the digest of the manifest the given image refers to.
+// The image version defaults to "latest". If the image already has a digest,
+// the registry is asked for that digest instead of the version.
+func (c *RegistryClient) ResolveDigest(ctx context.Context, img DockerImage) (string, error) {
+	ref := img.DefaultLatestVersion().Version
+	if img.Digest != "" {
+		ref = img.Digest
+	}
+	manifestURL := c.registryURL(img, "/manifests/"+ref)
+
+	resp, err := c.do(ctx, img, http.MethodHead, manifestURL, manifestMediaTypes, ErrManifestNotFound)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+
+	if digest := resp.Header.Get("Docker-Content-Digest"); digest != "" {
+		if !isDigest(digest) {
+			return "", notef(ErrRegistry, "Invalid digest %#v", digest)
+		}
+		return digest, nil
+	}
+
+	// Some registries do not send the digest header for HEAD requests, so we
+	// fetch the manifest and compute the digest ourselves.
+	resp, err = c.do(ctx, img, http.MethodGet, manifestURL, manifestMediaTypes, ErrManifestNotFound)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, resp.Body); err != nil {
+		return "", maskAny(err)
+	}
+	return "sha256:" + hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// ListTags returns all tags of the repository of the given image, following
+// the registry's pagination. Tags are returned as listed by the registry, use
+// PatternVersion to filter the tags that DockerImage.WithTag accepts. A Link
+// header pointing to an already visited page is an error.
+func (c *RegistryClient) ListTags(ctx context.Context, img DockerImage) ([]string, error) {
+	tags := []string{}
+	nextURL := c.registryURL(img, "/tags/list")
+	visited := map[string]bool{}
+
+	for nextURL != "" {
+		if visited[nextURL] {
+			return nil, notef(ErrRegistry, "Tag list pagination loops back to %s", nextURL)
+		}
+		visited[nextURL] = true
+
+		resp, err := c.do(ctx, img, http.MethodGet, nextURL, nil, ErrRepositoryNotFound)
+		if err != nil {
+			return nil, err
+		}
+
+		var page struct {
+			Tags []string `json:"tags"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return nil, errgo.Notef(err, "Invalid tag list response")
+		}
+
+		tags = append(tags, page.Tags...)
+
+		nextURL, err = nextPageURL(nextURL, resp.Header.Get("Link"))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return tags, nil
+}
+
+// do performs a request against the registry of the given image. When the
+// registry answers with a bearer token challenge, a token is fetched and the
+// request is retried once with it. A 404 response is reported as notFound.
+func (c *RegistryClient) do(ctx context.Context, img DockerImage, method, rawURL string, accept []string, notFound error) (*http.Response, error) {
+	resp, err := c.send(ctx, method, rawURL, accept, "")
+	if err != nil {
+		return nil, maskAny(err)
+	}
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		challenge := resp.Header.Get("WWW-Authenticate")
+		resp.Body.Close()
+
+		authorization, err := c.authorize(ctx, img, challenge)
+		if err != nil {
+			return nil, err
+		}
+		resp, err = c.send(ctx, method, rawURL, accept, authorization)
+		if err != nil {
+			return nil, maskAny(err)
+		}
+	}
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return resp, nil
+	case resp.StatusCode == http.StatusNotFound:
+		resp.Body.Close()
+		return nil, notef(notFound, "%s", img.String())
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		resp.Body.Close()
+		return nil, notef(ErrUnauthorized, "%s %s", method, rawURL)
+	default:
+		resp.Body.Close()
+		return nil, notef(ErrRegistry, "%s %s: %s", method, rawURL, resp.Status)
+	}
+}
+
+func (c *RegistryClient) send(ctx context.Context, method, rawURL string, accept []string, authorization string) (*http.Response, error) {
+	req, err := http.NewRequest(method, rawURL, nil)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	req = req.WithContext(ctx)
+
+	if len(accept) > 0 {
+		req.Header.Set("Accept", strings.Join(accept, ", "))
+	}
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	return resp, nil
+}
+
+// authorize answers the given WWW-Authenticate challenge and returns the
+// value for the Authorization header of the retried request.
+func (c *RegistryClient) authorize(ctx context.Context, img DockerImage, challenge string) (string, error) {
+	var creds DockerCredentials
+	if c.Credentials != nil {
+		var err error
+		if creds, err = c.Credentials.Resolve(img); err != nil {
+			return "", err
+		}
+	}
+
+	scheme, params := parseAuthChallenge(challenge)
+	switch {
+	case strings.EqualFold(scheme, "Bearer"):
+		return c.fetchToken(ctx, img, params, creds)
+	case strings.EqualFold(scheme, "Basic") && creds.Username != "":
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(creds.Username+":"+creds.Password)), nil
+	default:
+		return "", notef(ErrUnauthorized, "Unsupported auth challenge %#v", challenge)
+	}
+}
+
+// fetchToken requests a bearer token from the realm of a token challenge,
+// see https://docs.docker.com/registry/spec/auth/token/. Identity tokens are
+// exchanged using the OAuth2 refresh token flow.
+func (c *RegistryClient) fetchToken(ctx context.Context, img DockerImage, params map[string]string, creds DockerCredentials) (string, error) {
+	realm, err := url.Parse(params["realm"])
+	if err != nil || params["realm"] == "" {
+		return "", notef(ErrUnauthorized, "Invalid realm %#v in auth challenge", params["realm"])
+	}
+
+	scope := params["scope"]
+	if scope == "" {
+		scope = "repository:" + registryRepositoryName(img) + ":pull"
+	}
+
+	query := url.Values{}
+	if service := params["service"]; service != "" {
+		query.Set("service", service)
+	}
+	query.Set("scope", scope)
+
+	var req *http.Request
+	if creds.IdentityToken != "" {
+		query.Set("grant_type", "refresh_token")
+		query.Set("refresh_token", creds.IdentityToken)
+		query.Set("client_id", "generic-types-go")
+
+		req, err = http.NewRequest(http.MethodPost, realm.String(), strings.NewReader(query.Encode()))
+		if err != nil {
+			return "", maskAny(err)
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	} else {
+		realmQuery := realm.Query()
+		for key := range query {
+			realmQuery.Set(key, query.Get(key))
+		}
+		realm.RawQuery = realmQuery.Encode()
+
+		req, err = http.NewRequest(http.MethodGet, realm.String(), nil)
+		if err != nil {
+			return "", maskAny(err)
+		}
+		if creds.Username != "" {
+			req.SetBasicAuth(creds.Username, creds.Password)
+		}
+	}
+
+	resp, err := c.httpClient().Do(req.WithContext(ctx))
+	if err != nil {
+		return "", maskAny(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", notef(ErrUnauthorized, "Token request failed: %s", resp.Status)
+	}
+
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", errgo.Notef(err, "Invalid token response")
+	}
+
+	// The spec allows both fields, "token" wins if both are present.
+	if token.Token == "" {
+		token.Token = token.AccessToken
+	}
+	if token.Token == "" {
+		return "", notef(ErrUnauthorized, "Empty token in token response")
+	}
+	return "Bearer " + token.Token, nil
+}
+
+func (c *RegistryClient) httpClient() *http.Client {
+	if c.HTTPClient != nil {
+		return c.HTTPClient
+	}
+	return http.DefaultClient
+}
+
+// registryURL returns the URL of the given API path below /v2/<name>.
+func (c *RegistryClient) registryURL(img DockerImage, path string) string {
+	scheme := "https"
+	if c.PlainHTTP {
+		scheme = "http"
+	}
+	return scheme + "://" + registryHost(img) + "/v2/" + registryRepositoryName(img) + path
+}
+
+// registryHost returns the host to talk to for the given image, mapping the
+// Docker Hub aliases to the actual registry host.
+func registryHost(img DockerImage) string {
+	switch img.Registry {
+	case "", "docker.io", "index.docker.io":
+		return DockerHubRegistry
+	default:
+		return img.Registry
+	}
+}
+
+// registryRepositoryName returns the <name> used in registry API paths, which
+// is the image without registry and version. Official Docker Hub images live
+// in the library namespace.
+func registryRepositoryName(img DockerImage) string {
+	if registryHost(img) == DockerHubRegistry {
+		img = img.DefaultLibraryNamespace()
+	}
+	if img.Namespace == "" {
+		return img.Repository
+	}
+	return img.Namespace + "/" + img.Repository
+}
+
+// nextPageURL returns the absolute URL of the link with rel="next" in the
+// given Link header, or "" if there is none. Relative links are resolved
+// against the URL of the current page.
+func nextPageURL(currentURL, header string) (string, error) {
+	for _, link := range strings.Split(header, ",") {
+		parts := strings.Split(link, ";")
+		target := strings.TrimSpace(parts[0])
+		if !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+			continue
+		}
+
+		for _, param := range parts[1:] {
+			param = strings.Replace(strings.TrimSpace(param), " ", "", -1)
+			if param != `rel="next"` && param != "rel=next" {
+				continue
+			}
+
+			base, err := url.Parse(currentURL)
+			if err != nil {
+				return "", maskAny(err)
+			}
+			next, err := base.Parse(target[1 : len(target)-1])
+			if err != nil {
+				return "", notef(ErrRegistry, "Invalid Link header %#v", header)
+			}
+			return next.String(), nil
+		}
+	}
+	return "", nil
+}
+
+// parseAuthChallenge parses a WWW-Authenticate header value like
+// `Bearer realm="https://auth.docker.io/token",service="registry.docker.io"`.
+func parseAuthChallenge(header string) (string, map[string]string) {
+	params := map[string]string{}
+
+	header = strings.TrimSpace(header)
+	scheme := header
+	if i := strings.IndexByte(header, ' '); i >= 0 {
+		scheme = header[:i]
+		header = header[i+1:]
+	} else {
+		return scheme, params
+	}
+
+	for {
+		header = strings.TrimLeft(header, " ,")
+		i := strings.IndexByte(header, '=')
+		if i < 0 {
+			return scheme, params
+		}
+		key := strings.ToLower(strings.TrimSpace(header[:i]))
+		header = header[i+1:]
+
+		var value string
+		if strings.HasPrefix(header, `"`) {
+			// Quoted values may contain commas, e.g. "repository:foo:pull,push".
+			header = header[1:]
+			var b strings.Builder
+			for len(header) > 0 && header[0] != '"' {
+				if header[0] == '\\' && len(header) > 1 {
+					header = header[1:]
+				}
+				b.WriteByte(header[0])
+				header = header[1:]
+			}
+			value = b.String()
+			header = strings.TrimPrefix(header, `"`)
+		} else {
+			j := strings.IndexByte(header, ',')
+			if j < 0 {
+				j = len(header)
+			}
+			value = strings.TrimSpace(header[:j])
+			header = header[j:]
+		}
+		params[key] = value
+	}
+}
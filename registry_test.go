@@ -0,0 +1,360 @@
+package generictypes
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/juju/errgo"
+)
+
+const testRegistryToken = "secret-token"
+
+// testRegistry is a minimal stand-in for an OCI Distribution registry that
+// requires bearer tokens issued by its own /token endpoint.
+type testRegistry struct {
+	*httptest.Server
+
+	// manifests maps "<name>/<reference>" to the manifest body.
+	manifests map[string]string
+
+	// omitDigestHeader disables the Docker-Content-Digest header.
+	omitDigestHeader bool
+
+	// anonymous disables the token challenge.
+	anonymous bool
+
+	// tags maps "<name>" to the tags of the repository.
+	tags map[string][]string
+
+	// pageSize is the number of tags per tag list page, 0 means all.
+	pageSize int
+
+	// loopLink makes the Link header of every tag list page point to the
+	// first page.
+	loopLink bool
+
+	// username and password are required by the token endpoint if set.
+	username, password string
+}
+
+func newTestRegistry(t *testing.T) *testRegistry {
+	r := &testRegistry{manifests: map[string]string{}, tags: map[string][]string{}}
+	r.Server = httptest.NewServer(http.HandlerFunc(r.serveHTTP))
+	t.Cleanup(r.Close)
+	return r
+}
+
+// image returns an image pointing at the test registry.
+func (r *testRegistry) image(name string) DockerImage {
+	return MustParseDockerImage(strings.TrimPrefix(r.URL, "http://") + "/" + name)
+}
+
+func (r *testRegistry) client() *RegistryClient {
+	return &RegistryClient{HTTPClient: r.Client(), PlainHTTP: true}
+}
+
+func (r *testRegistry) serveHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.URL.Path == "/token" {
+		if username, password, _ := req.BasicAuth(); username != r.username || password != r.password {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"token": testRegistryToken})
+		return
+	}
+
+	if !r.anonymous && req.Header.Get("Authorization") != "Bearer "+testRegistryToken {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="`+r.URL+`/token",service="test"`)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	path := strings.TrimPrefix(req.URL.Path, "/v2/")
+	if strings.HasSuffix(path, "/tags/list") {
+		r.serveTags(w, req, strings.TrimSuffix(path, "/tags/list"))
+		return
+	}
+
+	i := strings.LastIndex(path, "/manifests/")
+	if i < 0 {
+		http.NotFound(w, req)
+		return
+	}
+	manifest, ok := r.manifests[path[:i]+"/"+path[i+len("/manifests/"):]]
+	if !ok {
+		http.NotFound(w, req)
+		return
+	}
+	if !strings.Contains(req.Header.Get("Accept"), MediaTypeOCIIndex) {
+		http.Error(w, "missing accept header", http.StatusBadRequest)
+		return
+	}
+
+	sum := sha256.Sum256([]byte(manifest))
+	w.Header().Set("Content-Type", MediaTypeOCIManifest)
+	if !r.omitDigestHeader {
+		w.Header().Set("Docker-Content-Digest", "sha256:"+hex.EncodeToString(sum[:]))
+	}
+	if req.Method == http.MethodGet {
+		w.Write([]byte(manifest))
+	}
+}
+
+// serveTags serves the tags following the last query parameter, paginated
+// via relative Link headers.
+func (r *testRegistry) serveTags(w http.ResponseWriter, req *http.Request, name string) {
+	tags, ok := r.tags[name]
+	if !ok {
+		http.NotFound(w, req)
+		return
+	}
+
+	if last := req.URL.Query().Get("last"); last != "" {
+		for i, tag := range tags {
+			if tag == last {
+				tags = tags[i+1:]
+				break
+			}
+		}
+	}
+	if r.pageSize > 0 && len(tags) > r.pageSize {
+		tags = tags[:r.pageSize]
+		w.Header().Set("Link", fmt.Sprintf(`</v2/%s/tags/list?n=%d&last=%s>; rel="next"`, name, r.pageSize, tags[len(tags)-1]))
+		if r.loopLink {
+			w.Header().Set("Link", fmt.Sprintf(`</v2/%s/tags/list>; rel="next"`, name))
+		}
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{"name": name, "tags": tags})
+}
+
+func testManifestDigest(manifest string) string {
+	sum := sha256.Sum256([]byte(manifest))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+func TestRegistryClient_ResolveDigest(t *testing.T) {
+	registry := newTestRegistry(t)
+	registry.manifests["app/1.2.3"] = `{"schemaVersion":2}`
+	registry.manifests["giantswarm/app/latest"] = `{"schemaVersion":2,"latest":true}`
+
+	var resolveDigests = []struct {
+		Name             string
+		Image            string
+		OmitDigestHeader bool
+		Expected         string
+	}{
+		{"tag", "app:1.2.3", false, testManifestDigest(`{"schemaVersion":2}`)},
+		{"default tag", "giantswarm/app", false, testManifestDigest(`{"schemaVersion":2,"latest":true}`)},
+		{"computed digest", "app:1.2.3", true, testManifestDigest(`{"schemaVersion":2}`)},
+	}
+
+	for _, data := range resolveDigests {
+		registry.omitDigestHeader = data.OmitDigestHeader
+
+		digest, err := registry.client().ResolveDigest(context.Background(), registry.image(data.Image))
+		if err != nil {
+			t.Fatalf("%s: Expected no error, got %v", data.Name, err)
+		}
+		if digest != data.Expected {
+			t.Fatalf("%s: Expected digest '%s', got '%s'", data.Name, data.Expected, digest)
+		}
+	}
+}
+
+func TestRegistryClient_ResolveDigestWithCredentials(t *testing.T) {
+	registry := newTestRegistry(t)
+	registry.manifests["app/1.2.3"] = `{"schemaVersion":2}`
+	registry.username = "user"
+	registry.password = "secret"
+
+	client := registry.client()
+	img := registry.image("app:1.2.3")
+
+	if _, err := client.ResolveDigest(context.Background(), img); !errors.Is(err, ErrUnauthorized) {
+		t.Fatalf("Expected ErrUnauthorized without credentials, got %v", err)
+	}
+
+	client.Credentials = &CredentialResolver{Config: DockerConfig{
+		Auths: map[string]DockerAuthConfig{
+			img.Registry: {Username: "user", Password: "secret"},
+		},
+	}}
+
+	digest, err := client.ResolveDigest(context.Background(), img)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if digest != testManifestDigest(`{"schemaVersion":2}`) {
+		t.Fatalf("Unexpected digest '%s'", digest)
+	}
+}
+
+func TestRegistryClient_ResolveDigestNotFound(t *testing.T) {
+	registry := newTestRegistry(t)
+
+	digest, err := registry.client().ResolveDigest(context.Background(), registry.image("app:1.2.3"))
+	if !errors.Is(err, ErrManifestNotFound) || errgo.Cause(err) != ErrManifestNotFound {
+		t.Fatalf("Expected ErrManifestNotFound for missing manifest, got '%s', %v", digest, err)
+	}
+}
+
+var listTags = []struct {
+	Name      string
+	Anonymous bool
+	PageSize  int
+}{
+	{"token auth", false, 0},
+	{"anonymous", true, 0},
+	{"paginated", false, 2},
+	{"paginated single tag pages", true, 1},
+}
+
+func TestRegistryClient_ListTags(t *testing.T) {
+	registry := newTestRegistry(t)
+	registry.tags["giantswarm/app"] = []string{"1.0.0", "1.1.0", "1.2.0", "latest", "2.0.0-rc1", "sha-abc123", "not+a+version"}
+
+	for _, data := range listTags {
+		registry.anonymous = data.Anonymous
+		registry.pageSize = data.PageSize
+
+		tags, err := registry.client().ListTags(context.Background(), registry.image("giantswarm/app:1.0.0"))
+		if err != nil {
+			t.Fatalf("%s: Expected no error, got %v", data.Name, err)
+		}
+		if strings.Join(tags, ",") != "1.0.0,1.1.0,1.2.0,latest,2.0.0-rc1,sha-abc123,not+a+version" {
+			t.Fatalf("%s: Unexpected tags %v", data.Name, tags)
+		}
+	}
+}
+
+func TestRegistryClient_ListTagsLinkLoop(t *testing.T) {
+	registry := newTestRegistry(t)
+	registry.anonymous = true
+	registry.tags["giantswarm/app"] = []string{"1.0.0", "1.1.0"}
+	registry.pageSize = 1
+	registry.loopLink = true
+
+	tags, err := registry.client().ListTags(context.Background(), registry.image("giantswarm/app"))
+	if !errors.Is(err, ErrRegistry) || !strings.Contains(err.Error(), "loops back") {
+		t.Fatalf("Expected ErrRegistry for looping Link header, got %v, %v", tags, err)
+	}
+}
+
+func TestRegistryClient_ListTagsNotFound(t *testing.T) {
+	registry := newTestRegistry(t)
+
+	tags, err := registry.client().ListTags(context.Background(), registry.image("giantswarm/app"))
+	if !errors.Is(err, ErrRepositoryNotFound) || errgo.Cause(err) != ErrRepositoryNotFound {
+		t.Fatalf("Expected ErrRepositoryNotFound for missing repository, got %v, %v", tags, err)
+	}
+
+	registry.tags["giantswarm/app"] = []string{"1.0.0"}
+	registry.username = "user"
+	registry.password = "secret"
+	tags, err = registry.client().ListTags(context.Background(), registry.image("giantswarm/app"))
+	if !errors.Is(err, ErrUnauthorized) || errgo.Cause(err) != ErrUnauthorized {
+		t.Fatalf("Expected ErrUnauthorized without credentials, got %v, %v", tags, err)
+	}
+}
+
+var nextPageURLs = []struct {
+	CurrentURL string
+	Header     string
+	Expected   string
+}{
+	{"https://quay.io/v2/app/tags/list", "", ""},
+	{"https://quay.io/v2/app/tags/list", `</v2/app/tags/list?n=2&last=b>; rel="next"`, "https://quay.io/v2/app/tags/list?n=2&last=b"},
+	{"https://quay.io/v2/app/tags/list", `<https://cdn.quay.io/v2/app/tags/list?last=b>; rel=next`, "https://cdn.quay.io/v2/app/tags/list?last=b"},
+	{"https://quay.io/v2/app/tags/list", `</v2/app/tags/list?last=a>; rel="prev", </v2/app/tags/list?last=c>; rel="next"`, "https://quay.io/v2/app/tags/list?last=c"},
+	{"https://quay.io/v2/app/tags/list", `</v2/app/tags/list?last=a>; rel="prev"`, ""},
+}
+
+func TestNextPageURL(t *testing.T) {
+	for _, data := range nextPageURLs {
+		next, err := nextPageURL(data.CurrentURL, data.Header)
+		if err != nil {
+			t.Fatalf("Expected no error for %#v, got %v", data.Header, err)
+		}
+		if next != data.Expected {
+			t.Fatalf("Expected next page '%s' for %#v, got '%s'", data.Expected, data.Header, next)
+		}
+	}
+}
+
+var authChallenges = []struct {
+	Input          string
+	ExpectedScheme string
+	ExpectedParams map[string]string
+}{
+	{
+		`Bearer realm="https://auth.docker.io/token",service="registry.docker.io",scope="repository:library/redis:pull"`,
+		"Bearer",
+		map[string]string{"realm": "https://auth.docker.io/token", "service": "registry.docker.io", "scope": "repository:library/redis:pull"},
+	},
+	{
+		`Bearer realm="https://auth.example.com/token", scope="repository:foo:pull,push"`,
+		"Bearer",
+		map[string]string{"realm": "https://auth.example.com/token", "scope": "repository:foo:pull,push"},
+	},
+	{
+		`Basic realm=registry`,
+		"Basic",
+		map[string]string{"realm": "registry"},
+	},
+	{
+		`Basic`,
+		"Basic",
+		map[string]string{},
+	},
+}
+
+func TestParseAuthChallenge(t *testing.T) {
+	for _, data := range authChallenges {
+		scheme, params := parseAuthChallenge(data.Input)
+		if scheme != data.ExpectedScheme {
+			t.Fatalf("Expected scheme '%s', got '%s'", data.ExpectedScheme, scheme)
+		}
+		if len(params) != len(data.ExpectedParams) {
+			t.Fatalf("Expected params %v, got %v", data.ExpectedParams, params)
+		}
+		for key, value := range data.ExpectedParams {
+			if params[key] != value {
+				t.Fatalf("Expected param %s to be '%s', got '%s'", key, value, params[key])
+			}
+		}
+	}
+}
+
+var registryRepositoryNames = []struct {
+	Image        string
+	ExpectedHost string
+	ExpectedName string
+}{
+	{"redis", DockerHubRegistry, "library/redis"},
+	{"giantswarm/app", DockerHubRegistry, "giantswarm/app"},
+	{"docker.io/redis", DockerHubRegistry, "library/redis"},
+	{"quay.io/giantswarm/app", "quay.io", "giantswarm/app"},
+	{"quay.io/app", "quay.io", "app"},
+}
+
+func TestRegistryRepositoryName(t *testing.T) {
+	for _, data := range registryRepositoryNames {
+		img := MustParseDockerImage(data.Image)
+
+		if host := registryHost(img); host != data.ExpectedHost {
+			t.Fatalf("Expected host '%s' for %s, got '%s'", data.ExpectedHost, data.Image, host)
+		}
+		if name := registryRepositoryName(img); name != data.ExpectedName {
+			t.Fatalf("Expected name '%s' for %s, got '%s'", data.ExpectedName, data.Image, name)
+		}
+	}
+}
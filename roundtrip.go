@@ -0,0 +1,258 @@
+package generictypes
+
+import (
+	"github.com/juju/errgo"
+
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+var (
+	ErrInvalidTarget = errgo.New("Target must be a non-nil pointer")
+)
+
+// JSONDiffKind describes how a value changed in a JSON round trip.
+type JSONDiffKind string
+
+const (
+	// JSONUnknownField is a field the target has no field for.
+	JSONUnknownField JSONDiffKind = "unknown field"
+
+	// JSONDroppedValue is a known field, map key or array item missing after
+	// the round trip, e.g. because of omitempty or a map key that is encoded
+	// differently.
+	JSONDroppedValue JSONDiffKind = "dropped value"
+
+	// JSONRepresentationChanged is a value encoded differently after the round
+	// trip, e.g. a port 6379 that became "6379/tcp".
+	JSONRepresentationChanged JSONDiffKind = "representation changed"
+)
+
+// JSONDiff is a single difference found by CheckJSONRoundTrip.
+type JSONDiff struct {
+	Kind JSONDiffKind
+
+	// Path is the path of the value, e.g. "services[2].ports[0]".
+	Path string
+
+	// Original is the decoded value of the input and RoundTripped the decoded
+	// value after the round trip, nil if missing. Numbers are json.Number.
+	Original     interface{}
+	RoundTripped interface{}
+}
+
+func (d JSONDiff) String() string {
+	switch d.Kind {
+	case JSONRepresentationChanged:
+		return fmt.Sprintf("%s: %s: %s became %s", d.Path, d.Kind, formatJSONValue(d.Original), formatJSONValue(d.RoundTripped))
+	default:
+		return fmt.Sprintf("%s: %s: %s", d.Path, d.Kind, formatJSONValue(d.Original))
+	}
+}
+
+// CheckJSONRoundTrip decodes data into target, encodes target again and
+// returns every difference between both documents, so that lossy configs can
+// be detected. Values only present after the round trip, like zero values of
+// fields without omitempty, are not reported. target must be a pointer, e.g.
+// to a config struct, and is filled as with json.Unmarshal.
+func CheckJSONRoundTrip(data []byte, target interface{}) ([]JSONDiff, error) {
+	value := reflect.ValueOf(target)
+	if value.Kind() != reflect.Ptr || value.IsNil() {
+		return nil, notef(ErrInvalidTarget, "Got %T", target)
+	}
+
+	original, err := decodeJSONValue(data)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	if err := json.Unmarshal(data, target); err != nil {
+		return nil, err
+	}
+	encoded, err := json.Marshal(target)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	roundTripped, err := decodeJSONValue(encoded)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+
+	var diffs []JSONDiff
+	compareJSONValues(original, roundTripped, value.Type(), "", &diffs)
+	return diffs, nil
+}
+
+func decodeJSONValue(data []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, maskAny(err)
+	}
+	return value, nil
+}
+
+// compareJSONValues adds the differences between the original and round
+// tripped value at path to diffs. t is the Go type the value was decoded
+// into, or nil if unknown.
+func compareJSONValues(original, roundTripped interface{}, t reflect.Type, path string, diffs *[]JSONDiff) {
+	switch original := original.(type) {
+	case map[string]interface{}:
+		other, ok := roundTripped.(map[string]interface{})
+		if !ok {
+			break
+		}
+
+		keys := make([]string, 0, len(original))
+		for key := range original {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			keyPath := key
+			if path != "" {
+				keyPath = path + "." + key
+			}
+			fieldType, known := jsonFieldType(t, key)
+
+			value, ok := other[key]
+			if !ok {
+				// Due to case insensitive matching, the field may be encoded with
+				// a different case.
+				for otherKey := range other {
+					if _, exists := original[otherKey]; !exists && strings.EqualFold(key, otherKey) {
+						value, ok = other[otherKey], true
+						break
+					}
+				}
+			}
+			switch {
+			case !known:
+				*diffs = append(*diffs, JSONDiff{Kind: JSONUnknownField, Path: keyPath, Original: original[key]})
+			case !ok && original[key] != nil:
+				*diffs = append(*diffs, JSONDiff{Kind: JSONDroppedValue, Path: keyPath, Original: original[key]})
+			case ok:
+				compareJSONValues(original[key], value, fieldType, keyPath, diffs)
+			}
+		}
+		return
+	case []interface{}:
+		other, ok := roundTripped.([]interface{})
+		if !ok {
+			break
+		}
+
+		elemType := jsonElemType(t)
+		for i, item := range original {
+			itemPath := fmt.Sprintf("%s[%d]", path, i)
+			if i >= len(other) {
+				*diffs = append(*diffs, JSONDiff{Kind: JSONDroppedValue, Path: itemPath, Original: item})
+				continue
+			}
+			compareJSONValues(item, other[i], elemType, itemPath, diffs)
+		}
+		return
+	}
+
+	if !reflect.DeepEqual(original, roundTripped) {
+		*diffs = append(*diffs, JSONDiff{Kind: JSONRepresentationChanged, Path: path, Original: original, RoundTripped: roundTripped})
+	}
+}
+
+var jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+// jsonFieldType returns the type of the given key of a JSON object decoded
+// into t. known is false if t is a struct without a matching field. Types
+// with custom decoding are opaque, so all their keys are considered known.
+func jsonFieldType(t reflect.Type, key string) (fieldType reflect.Type, known bool) {
+	t = jsonIndirect(t)
+	if t == nil {
+		return nil, true
+	}
+
+	switch t.Kind() {
+	case reflect.Map:
+		return t.Elem(), true
+	case reflect.Struct:
+		var caseInsensitive reflect.Type
+		found := false
+		for _, field := range jsonStructFields(t) {
+			name := fieldName(field)
+			if name == key {
+				return field.Type, true
+			}
+			if !found && strings.EqualFold(name, key) {
+				caseInsensitive, found = field.Type, true
+			}
+		}
+		return caseInsensitive, found
+	default:
+		return nil, true
+	}
+}
+
+// jsonElemType returns the item type of a JSON array decoded into t.
+func jsonElemType(t reflect.Type) reflect.Type {
+	t = jsonIndirect(t)
+	if t != nil && (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) {
+		return t.Elem()
+	}
+	return nil
+}
+
+// jsonIndirect dereferences pointers and returns nil for types with unknown
+// structure, i.e. interfaces and types implementing json.Unmarshaler.
+func jsonIndirect(t reflect.Type) reflect.Type {
+	for t != nil {
+		if t.Implements(jsonUnmarshalerType) || reflect.PtrTo(t).Implements(jsonUnmarshalerType) {
+			return nil
+		}
+		switch t.Kind() {
+		case reflect.Ptr:
+			t = t.Elem()
+		case reflect.Interface:
+			return nil
+		default:
+			return t
+		}
+	}
+	return nil
+}
+
+// jsonStructFields returns the exported fields of t including the fields of
+// embedded structs without a JSON name, which encoding/json promotes.
+func jsonStructFields(t reflect.Type) []reflect.StructField {
+	var fields []reflect.StructField
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Anonymous && field.Tag.Get("json") == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				fields = append(fields, jsonStructFields(embedded)...)
+				continue
+			}
+		}
+		if field.PkgPath != "" || fieldName(field) == "" {
+			continue
+		}
+		fields = append(fields, field)
+	}
+	return fields
+}
+
+func formatJSONValue(value interface{}) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	return string(data)
+}
@@ -0,0 +1,134 @@
+package generictypes
+
+import (
+	"testing"
+)
+
+type roundTripService struct {
+	Image    DockerImage        `json:"image"`
+	Ports    []DockerPort       `json:"ports"`
+	Exposed  map[DockerPort]int `json:"exposed,omitempty"`
+	Platform *Platform          `json:"platform,omitempty"`
+	Replicas int                `json:"replicas,omitempty"`
+	Ignored  string             `json:"-"`
+}
+
+type roundTripConfig struct {
+	Services []roundTripService `json:"services"`
+	Labels   map[string]string  `json:"labels,omitempty"`
+	Extra    interface{}        `json:"extra,omitempty"`
+}
+
+func TestCheckJSONRoundTrip_Lossless(t *testing.T) {
+	input := `{
+		"services": [
+			{"image": "redis:3.0", "ports": [6379, "6380", "53/udp"], "replicas": 2},
+			{"image": "quay.io/giantswarm/app", "ports": [], "platform": "linux/arm64"}
+		],
+		"labels": {"team": "giantswarm"},
+		"extra": {"anything": [1, "two", null]}
+	}`
+
+	var config roundTripConfig
+	diffs, err := CheckJSONRoundTrip([]byte(input), &config)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(diffs) != 0 {
+		t.Fatalf("Expected no diffs, got %v", diffs)
+	}
+	if config.Services[0].Ports[0].Port != "6379" {
+		t.Fatalf("Expected target to be filled, got %#v", config)
+	}
+}
+
+func TestCheckJSONRoundTrip_Diffs(t *testing.T) {
+	input := `{
+		"services": [
+			{
+				"image": "redis:3.0",
+				"ports": [6379],
+				"exposed": {"443": 1},
+				"platform": "linux/aarch64",
+				"replicas": 0,
+				"-": "hidden",
+				"command": ["redis-server"]
+			}
+		],
+		"version": 2
+	}`
+
+	var config roundTripConfig
+	diffs, err := CheckJSONRoundTrip([]byte(input), &config)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	var expected = []struct {
+		Kind JSONDiffKind
+		Path string
+	}{
+		{JSONUnknownField, "services[0].-"},
+		{JSONUnknownField, "services[0].command"},
+		{JSONDroppedValue, "services[0].exposed.443"},
+		{JSONRepresentationChanged, "services[0].platform"},
+		{JSONDroppedValue, "services[0].replicas"},
+		{JSONUnknownField, "version"},
+	}
+	if len(diffs) != len(expected) {
+		t.Fatalf("Expected %d diffs, got %v", len(expected), diffs)
+	}
+	for i, data := range expected {
+		if diffs[i].Kind != data.Kind || diffs[i].Path != data.Path {
+			t.Fatalf("Expected %s at '%s', got %v", data.Kind, data.Path, diffs[i])
+		}
+	}
+
+	if s := diffs[3].String(); s != `services[0].platform: representation changed: "linux/aarch64" became "linux/arm64"` {
+		t.Fatalf("Unexpected diff description '%s'", s)
+	}
+}
+
+func TestCheckJSONRoundTrip_PortRepresentation(t *testing.T) {
+	var ports struct {
+		Ports []DockerPort `json:"ports"`
+	}
+	diffs, err := CheckJSONRoundTrip([]byte(`{"ports": [6379, "6380", "53/udp"]}`), &ports)
+	if err != nil || len(diffs) != 0 {
+		t.Fatalf("Expected no diffs, got %v, %v", diffs, err)
+	}
+
+	// A port stored in a string field has the wrong type.
+	var text struct {
+		Ports []string `json:"ports"`
+	}
+	if _, err := CheckJSONRoundTrip([]byte(`{"ports": [6379]}`), &text); err == nil {
+		t.Fatalf("Expected decoding error")
+	}
+
+	// A port stored in an interface is kept as is.
+	var raw struct {
+		Ports interface{} `json:"ports"`
+	}
+	diffs, err = CheckJSONRoundTrip([]byte(`{"ports": [6379, 6379.0]}`), &raw)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(diffs) != 1 || diffs[0].Kind != JSONRepresentationChanged || diffs[0].Path != "ports[1]" {
+		t.Fatalf("Expected representation change of ports[1], got %v", diffs)
+	}
+}
+
+func TestCheckJSONRoundTrip_Errors(t *testing.T) {
+	var config roundTripConfig
+
+	if _, err := CheckJSONRoundTrip([]byte(`{}`), config); err == nil {
+		t.Fatalf("Expected error for non-pointer target")
+	}
+	if _, err := CheckJSONRoundTrip([]byte(`{`), &config); err == nil {
+		t.Fatalf("Expected error for invalid JSON")
+	}
+	if _, err := CheckJSONRoundTrip([]byte(`{"services": [{"ports": ["80/icmp"]}]}`), &config); err == nil {
+		t.Fatalf("Expected error for invalid port")
+	}
+}
@@ -0,0 +1,291 @@
+package generictypes
+
+import (
+	"github.com/juju/errgo"
+
+	"bufio"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	ErrUnknownService = errgo.New("Unknown service name")
+)
+
+// defaultServices is a subset of the IANA service name registry in the
+// /etc/services format.
+const defaultServices = `
+ftp-data	20/tcp
+ftp		21/tcp
+ssh		22/tcp
+telnet		23/tcp
+smtp		25/tcp		mail
+domain		53/tcp		dns
+domain		53/udp		dns
+bootps		67/udp		dhcp
+bootpc		68/udp
+tftp		69/udp
+http		80/tcp		www www-http
+kerberos	88/tcp		kerberos5 krb5
+kerberos	88/udp		kerberos5 krb5
+pop3		110/tcp		pop-3
+ntp		123/udp
+imap		143/tcp		imap2
+snmp		161/tcp
+snmp		161/udp
+snmp-trap	162/udp		snmptrap
+ldap		389/tcp
+ldap		389/udp
+https		443/tcp
+https		443/udp		quic
+syslog		514/udp
+submission	587/tcp
+ldaps		636/tcp
+imaps		993/tcp
+pop3s		995/tcp
+mssql		1433/tcp	ms-sql-s
+oracle		1521/tcp
+openvpn		1194/tcp
+openvpn		1194/udp
+mqtt		1883/tcp
+nfs		2049/tcp
+nfs		2049/udp
+etcd-client	2379/tcp
+etcd-server	2380/tcp
+mysql		3306/tcp
+rdp		3389/tcp	ms-wbt-server
+postgresql	5432/tcp	postgres
+amqp		5672/tcp
+redis		6379/tcp
+http-alt	8080/tcp	webcache
+mongodb		27017/tcp
+`
+
+// DefaultServices is the service table used by ParseDockerPortService. It
+// contains common IANA services, e.g. "http", "https" and "domain" (alias
+// "dns").
+var DefaultServices = mustParseServices(defaultServices)
+
+// serviceKey is a service name in lower case and its protocol.
+type serviceKey struct {
+	Name     string
+	Protocol string
+}
+
+// Services maps service names to port numbers and back, like /etc/services.
+// Names are case insensitive. Only tcp and udp services are supported.
+// Services is read-only and safe for concurrent use.
+type Services struct {
+	ports map[serviceKey]uint16
+	names map[DockerPort]string
+}
+
+// ParseServices parses a service table in the /etc/services format:
+//
+//	http	80/tcp	www	# comment
+//
+// Entries with other protocols than tcp and udp are ignored. If a name or
+// port is given more than once, the first entry wins. Name() returns the first
+// name of a port.
+func ParseServices(r io.Reader) (*Services, error) {
+	s := &Services{
+		ports: map[serviceKey]uint16{},
+		names: map[DockerPort]string{},
+	}
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if i := strings.Index(text, "#"); i >= 0 {
+			text = text[:i]
+		}
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) < 2 {
+			return nil, errgo.Newf("Line %d: Invalid service entry %#v", line, scanner.Text())
+		}
+
+		parts := strings.Split(fields[1], "/")
+		if len(parts) != 2 {
+			return nil, errgo.Newf("Line %d: Invalid service port %#v", line, fields[1])
+		}
+		if parts[1] != ProtocolTCP && parts[1] != ProtocolUDP {
+			continue
+		}
+		port, err := ParseDockerPort(fields[1])
+		if err != nil {
+			return nil, notef(err, "Line %d", line)
+		}
+
+		// Names that cannot be written as port, like "914c/g", are skipped.
+		for _, name := range append([]string{fields[0]}, fields[2:]...) {
+			if !isServiceName(name) {
+				continue
+			}
+			name = strings.ToLower(name)
+			key := serviceKey{Name: name, Protocol: port.Protocol}
+			if _, ok := s.ports[key]; !ok {
+				s.ports[key] = port.Number()
+			}
+			if _, ok := s.names[portSetKey(port)]; !ok {
+				s.names[portSetKey(port)] = name
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errgo.Mask(err)
+	}
+	return s, nil
+}
+
+func mustParseServices(table string) *Services {
+	s, err := ParseServices(strings.NewReader(table))
+	if err != nil {
+		panic(err.Error())
+	}
+	return s
+}
+
+// LoadServices reads a service table like /etc/services from the given path.
+// Its entries take precedence over DefaultServices, which is used for all
+// names and ports the file does not contain. Ports whose default name the file
+// assigns to another port have no name.
+func LoadServices(path string) (*Services, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	defer f.Close()
+
+	s, err := ParseServices(f)
+	if err != nil {
+		return nil, notef(err, "Cannot parse %s", path)
+	}
+	for key, port := range DefaultServices.ports {
+		if _, ok := s.ports[key]; !ok {
+			s.ports[key] = port
+		}
+	}
+	for port, name := range DefaultServices.names {
+		// Names the file moved to another port no longer name this one.
+		if number := s.ports[serviceKey{Name: name, Protocol: port.Protocol}]; number != port.Number() {
+			continue
+		}
+		if _, ok := s.names[port]; !ok {
+			s.names[port] = name
+		}
+	}
+	return s, nil
+}
+
+// Lookup returns the port of the given service name and protocol.
+func (s *Services) Lookup(name, protocol string) (DockerPort, bool) {
+	number, ok := s.ports[serviceKey{Name: strings.ToLower(name), Protocol: protocol}]
+	if !ok {
+		return DockerPort{}, false
+	}
+	return DockerPort{Port: strconv.FormatUint(uint64(number), 10), Protocol: protocol}, true
+}
+
+// Name returns the service name of the given port for display, e.g. "https"
+// for 443/tcp.
+func (s *Services) Name(port DockerPort) (string, bool) {
+	name, ok := s.names[portSetKey(port)]
+	return name, ok
+}
+
+// ParseDockerPort parses a port like ParseDockerPort, but also accepts
+// service names instead of port numbers, e.g. "http", "https/tcp" or
+// "dns/udp". The name is kept and used when marshalling the port, see
+// DockerPort.ServiceName(). Unknown names return a PortError with
+// ErrUnknownService.
+func (s *Services) ParseDockerPort(input string) (DockerPort, error) {
+	parts := strings.Split(input, "/")
+	if len(parts) > 2 || !isServiceName(parts[0]) {
+		return ParseDockerPort(input)
+	}
+
+	result := DockerPort{Protocol: ProtocolTCP, formatJsonMode: PortFormatString}
+	if len(parts) == 2 {
+		result.Protocol = parts[1]
+		result.formatJsonMode = PortFormatDocker
+	}
+	switch result.Protocol {
+	case ProtocolTCP, ProtocolUDP:
+	case "":
+		return DockerPort{}, newPortError(input, ErrInvalidProtocol, "Protocol must not be empty.")
+	default:
+		return DockerPort{}, newPortError(input, ErrInvalidProtocol, "Unknown protocol: '%s' in '%s'", result.Protocol, input)
+	}
+
+	port, ok := s.Lookup(parts[0], result.Protocol)
+	if !ok {
+		return DockerPort{}, newPortError(input, ErrUnknownService, "Unknown service '%s' for protocol %s", parts[0], result.Protocol)
+	}
+	result.Port = port.Port
+	result.serviceName = parts[0]
+	return result, nil
+}
+
+// ParseDockerPortService parses a port or service name with DefaultServices.
+func ParseDockerPortService(input string) (DockerPort, error) {
+	return DefaultServices.ParseDockerPort(input)
+}
+
+// decodeServices is the service table set with SetDecodeServices, nil if
+// service names are not decoded.
+var decodeServices struct {
+	sync.RWMutex
+	services *Services
+}
+
+// SetDecodeServices enables service names when decoding a DockerPort with
+// UnmarshalJSON, UnmarshalYAML, UnmarshalText, Set or Scan, e.g. "http" or
+// "dns/udp" resolved with the given table. Passing nil disables them again,
+// which is the default. Ports parsed from a name are marshalled as the name
+// only while the table resolves it to the same port.
+func SetDecodeServices(services *Services) {
+	decodeServices.Lock()
+	defer decodeServices.Unlock()
+	decodeServices.services = services
+}
+
+func getDecodeServices() *Services {
+	decodeServices.RLock()
+	defer decodeServices.RUnlock()
+	return decodeServices.services
+}
+
+// parseDockerPortOrService parses a port like parseDockerPort, but also
+// resolves service names if SetDecodeServices enabled them.
+func parseDockerPortOrService(input string, dp *DockerPort) error {
+	services := getDecodeServices()
+	if services == nil {
+		return parseDockerPort(input, dp)
+	}
+	port, err := services.ParseDockerPort(input)
+	if err != nil {
+		return err
+	}
+	*dp = port
+	return nil
+}
+
+// isServiceName returns true for names like "http" or "ftp-data": letters,
+// digits and hyphens with at least one letter.
+func isServiceName(name string) bool {
+	if name == "" || strings.Trim(name, "0123456789") == "" {
+		return false
+	}
+	for _, c := range name {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-') {
+			return false
+		}
+	}
+	return true
+}
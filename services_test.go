@@ -0,0 +1,362 @@
+package generictypes
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/juju/errgo"
+	"gopkg.in/yaml.v3"
+)
+
+var servicePorts = []struct {
+	Input       string
+	Port        string
+	ServiceName string
+	JSON        string
+}{
+	{"http", "80/tcp", "http", `"http"`},
+	{"https/tcp", "443/tcp", "https", `"https/tcp"`},
+	{"dns/udp", "53/udp", "dns", `"dns/udp"`},
+	{"HTTP", "80/tcp", "HTTP", `"HTTP"`},
+	{"www/tcp", "80/tcp", "www", `"www/tcp"`},
+	{"8080", "8080/tcp", "", `"8080"`},
+	{"53/udp", "53/udp", "", `"53/udp"`},
+}
+
+// enableDecodeServices enables service names for decoding until the test ends.
+func enableDecodeServices(t *testing.T, services *Services) {
+	SetDecodeServices(services)
+	t.Cleanup(func() { SetDecodeServices(nil) })
+}
+
+func TestParseDockerPortService(t *testing.T) {
+	enableDecodeServices(t, DefaultServices)
+	for _, data := range servicePorts {
+		port, err := ParseDockerPortService(data.Input)
+		if err != nil {
+			t.Fatalf("Expected no error for '%s', got %v", data.Input, err)
+		}
+		if port.String() != data.Port {
+			t.Fatalf("Expected %s for '%s', got %s", data.Port, data.Input, port.String())
+		}
+		if port.ServiceName() != data.ServiceName {
+			t.Fatalf("Expected service name '%s' for '%s', got '%s'", data.ServiceName, data.Input, port.ServiceName())
+		}
+		if err := port.Validate(); err != nil {
+			t.Fatalf("Expected valid port for '%s', got %v", data.Input, err)
+		}
+
+		output, err := json.Marshal(port)
+		if err != nil {
+			t.Fatalf("Expected no error for '%s', got %v", data.Input, err)
+		}
+		if string(output) != data.JSON {
+			t.Fatalf("Expected %s for '%s', got %s", data.JSON, data.Input, output)
+		}
+		var input string
+		if err := json.Unmarshal(output, &input); err != nil {
+			t.Fatalf("Expected no error for '%s', got %v", data.Input, err)
+		}
+		if parsed, err := ParseDockerPortService(input); err != nil || parsed != port {
+			t.Fatalf("Expected '%s' to round-trip, got %v, %v", data.Input, parsed, err)
+		}
+	}
+}
+
+func TestParseDockerPortServiceErrors(t *testing.T) {
+	var portErrors = []struct {
+		Input    string
+		Expected error
+	}{
+		{"unknown", ErrUnknownService},
+		{"ntp/tcp", ErrUnknownService},
+		{"http/", ErrInvalidProtocol},
+		{"http/icmp", ErrInvalidProtocol},
+		{"http/tcp/udp", ErrInvalidPortFormat},
+		{"http_alt", ErrInvalidPortNumber},
+		{"0", ErrInvalidPortNumber},
+	}
+	for _, data := range portErrors {
+		if port, err := ParseDockerPortService(data.Input); !errors.Is(err, data.Expected) {
+			t.Fatalf("Expected %v for '%s', got %v, %v", data.Expected, data.Input, port, err)
+		}
+	}
+	if _, err := ParseDockerPort("http"); !errors.Is(err, ErrInvalidPortNumber) {
+		t.Fatalf("Expected ParseDockerPort to reject service names, got %v", err)
+	}
+}
+
+func TestDockerPort_ServiceNamesOptIn(t *testing.T) {
+	port, err := ParseDockerPortService("http")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if output, _ := json.Marshal(port); string(output) != `"80"` {
+		t.Fatalf("Expected \"80\" without SetDecodeServices, got %s", output)
+	}
+	if output, _ := yaml.Marshal(port); string(output) != "\"80\"\n" {
+		t.Fatalf("Expected \"80\" without SetDecodeServices, got %s", output)
+	}
+
+	decoders := map[string]func(p *DockerPort, input string) error{
+		"JSON": func(p *DockerPort, input string) error { return json.Unmarshal([]byte(`"`+input+`"`), p) },
+		"YAML": func(p *DockerPort, input string) error { return yaml.Unmarshal([]byte(input), p) },
+		"Text": func(p *DockerPort, input string) error { return p.UnmarshalText([]byte(input)) },
+		"Set":  func(p *DockerPort, input string) error { return p.Set(input) },
+		"Scan": func(p *DockerPort, input string) error { return p.Scan(input) },
+	}
+	for name, decode := range decoders {
+		var port DockerPort
+		if err := decode(&port, "dns/udp"); !errors.Is(err, ErrInvalidPortNumber) {
+			t.Fatalf("Expected %s to reject service names by default, got %v, %v", name, port, err)
+		}
+	}
+
+	enableDecodeServices(t, DefaultServices)
+	for name, decode := range decoders {
+		var port DockerPort
+		if err := decode(&port, "dns/udp"); err != nil {
+			t.Fatalf("Expected no error for %s, got %v", name, err)
+		}
+		if port.String() != "53/udp" || port.ServiceName() != "dns" {
+			t.Fatalf("Expected dns as 53/udp for %s, got %#v", name, port)
+		}
+		if err := decode(&port, "unknown"); !errors.Is(err, ErrUnknownService) {
+			t.Fatalf("Expected ErrUnknownService for %s, got %v", name, err)
+		}
+	}
+}
+
+func TestDockerPort_ServiceNameFormat(t *testing.T) {
+	enableDecodeServices(t, DefaultServices)
+	port, err := ParseDockerPortService("https")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if port.Format() != PortFormatString || !port.Equals(MustParseDockerPort("443/tcp")) {
+		t.Fatalf("Expected 443/tcp in string format, got %#v", port)
+	}
+
+	output, err := yaml.Marshal(port)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if string(output) != "https\n" {
+		t.Fatalf("Expected https, got %s", output)
+	}
+	if text, _ := port.MarshalText(); string(text) != "443/tcp" {
+		t.Fatalf("Expected 443/tcp as text, got %s", text)
+	}
+
+	numeric, err := port.WithFormat(PortFormatNumber)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if numeric.ServiceName() != "" {
+		t.Fatalf("Expected WithFormat to drop the service name, got '%s'", numeric.ServiceName())
+	}
+	if output, _ := json.Marshal(numeric); string(output) != "443" {
+		t.Fatalf("Expected 443, got %s", output)
+	}
+
+	set, err := NewPortSet(port, MustParseDockerPort("443"))
+	if err == nil {
+		t.Fatalf("Expected https and 443 to be the same port, got %v", set)
+	}
+}
+
+func TestDockerPort_ServiceNameRoundTrip(t *testing.T) {
+	type config struct {
+		Port  DockerPort   `json:"port" yaml:"port"`
+		Ports []DockerPort `json:"ports" yaml:"ports"`
+	}
+	enableDecodeServices(t, DefaultServices)
+
+	custom, err := ParseServices(strings.NewReader("http 8080/tcp\nmyapp 9000/tcp\n"))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	var input config
+	for i, name := range []string{"http", "dns/udp", "HTTP", "http", "myapp/tcp", "8443"} {
+		services := DefaultServices
+		if i >= 3 {
+			services = custom
+		}
+		port, err := services.ParseDockerPort(name)
+		if err != nil {
+			t.Fatalf("Expected no error for '%s', got %v", name, err)
+		}
+		input.Ports = append(input.Ports, port)
+	}
+	input.Port = input.Ports[0]
+
+	// Names that the decode services resolve differently are marshalled as
+	// number.
+	data, err := json.Marshal(input)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	expected := `{"port":"http","ports":["http","dns/udp","HTTP","8080","9000/tcp","8443"]}`
+	if string(data) != expected {
+		t.Fatalf("Expected %s, got %s", expected, data)
+	}
+	var decoded config
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	for i, port := range decoded.Ports {
+		if !port.Equals(input.Ports[i]) {
+			t.Fatalf("Expected %v after JSON round trip, got %v", input.Ports[i], port)
+		}
+	}
+	if decoded.Port != input.Port || decoded.Ports[1].ServiceName() != "dns" {
+		t.Fatalf("Expected service names to survive the JSON round trip, got %#v", decoded)
+	}
+
+	data, err = yaml.Marshal(input)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	decoded = config{}
+	if err := yaml.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Expected no error for %s, got %v", data, err)
+	}
+	for i, port := range decoded.Ports {
+		if !port.Equals(input.Ports[i]) {
+			t.Fatalf("Expected %v after YAML round trip of %s, got %v", input.Ports[i], data, port)
+		}
+	}
+	if decoded.Port != input.Port || decoded.Ports[2].ServiceName() != "HTTP" {
+		t.Fatalf("Expected service names to survive the YAML round trip, got %#v", decoded)
+	}
+
+	var port DockerPort
+	if err := json.Unmarshal([]byte(`"unknown"`), &port); !errors.Is(err, ErrUnknownService) {
+		t.Fatalf("Expected ErrUnknownService for unknown service, got %v", err)
+	}
+}
+
+func TestServices_Name(t *testing.T) {
+	var names = []struct {
+		Port     string
+		Expected string
+	}{
+		{"80/tcp", "http"},
+		{"53/udp", "domain"},
+		{"443", "https"},
+		{"123/udp", "ntp"},
+		{"123/tcp", ""},
+		{"12345/tcp", ""},
+	}
+	for _, data := range names {
+		name, ok := DefaultServices.Name(MustParseDockerPort(data.Port))
+		if name != data.Expected || ok != (data.Expected != "") {
+			t.Fatalf("Expected '%s' for %s, got '%s'", data.Expected, data.Port, name)
+		}
+	}
+}
+
+func TestParseServices(t *testing.T) {
+	table := `
+# Comment
+tcpmux		1/tcp				# TCP port service multiplexer
+echo		7/tcp
+echo		7/udp
+914c/g		211/tcp		914c-g
+http		8000/tcp	web
+http		80/tcp		www
+afpovertcp	548/ddp
+`
+	services, err := ParseServices(strings.NewReader(table))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	var lookups = []struct {
+		Name     string
+		Protocol string
+		Expected string
+	}{
+		{"tcpmux", "tcp", "1/tcp"},
+		{"ECHO", "udp", "7/udp"},
+		{"914c-g", "tcp", "211/tcp"},
+		{"http", "tcp", "8000/tcp"},
+		{"web", "tcp", "8000/tcp"},
+		{"www", "tcp", "80/tcp"},
+		{"914c/g", "tcp", ""},
+		{"afpovertcp", "tcp", ""},
+		{"https", "tcp", ""},
+	}
+	for _, data := range lookups {
+		port, ok := services.Lookup(data.Name, data.Protocol)
+		if !ok {
+			if data.Expected != "" {
+				t.Fatalf("Expected %s for '%s', got nothing", data.Expected, data.Name)
+			}
+			continue
+		}
+		if port.String() != data.Expected {
+			t.Fatalf("Expected '%s' for '%s', got %s", data.Expected, data.Name, port.String())
+		}
+	}
+	if name, _ := services.Name(MustParseDockerPort("211/tcp")); name != "914c-g" {
+		t.Fatalf("Expected 914c-g for 211/tcp, got '%s'", name)
+	}
+
+	for _, table := range []string{"http", "http 80", "http 0/tcp", "http 80/tcp/udp"} {
+		if _, err := ParseServices(strings.NewReader(table)); err == nil {
+			t.Fatalf("Expected error for '%s'", table)
+		}
+	}
+}
+
+func TestLoadServices(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "services")
+	if err := os.WriteFile(path, []byte("http\t8080/tcp\nmyapp\t9000/tcp\n"), 0644); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	services, err := LoadServices(path)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	var ports = []struct {
+		Input    string
+		Expected string
+	}{
+		{"http", "8080/tcp"},
+		{"myapp", "9000/tcp"},
+		{"https", "443/tcp"},
+	}
+	for _, data := range ports {
+		port, err := services.ParseDockerPort(data.Input)
+		if err != nil {
+			t.Fatalf("Expected no error for '%s', got %v", data.Input, err)
+		}
+		if port.String() != data.Expected {
+			t.Fatalf("Expected %s for '%s', got %s", data.Expected, data.Input, port.String())
+		}
+	}
+	var names = []struct {
+		Port     string
+		Expected string
+	}{
+		{"8080/tcp", "http"},
+		{"9000/tcp", "myapp"},
+		{"80/tcp", ""},
+		{"443/tcp", "https"},
+	}
+	for _, data := range names {
+		if name, _ := services.Name(MustParseDockerPort(data.Port)); name != data.Expected {
+			t.Fatalf("Expected name '%s' for %s, got '%s'", data.Expected, data.Port, name)
+		}
+	}
+
+	if _, err := LoadServices(filepath.Join(t.TempDir(), "missing")); !os.IsNotExist(errgo.Cause(err)) {
+		t.Fatalf("Expected not exist error, got %v", err)
+	}
+}
@@ -0,0 +1,153 @@
+package generictypes
+
+import (
+	"github.com/juju/errgo"
+
+	"database/sql/driver"
+)
+
+// Scan implements sql.Scanner. Use NullDockerImage for nullable columns.
+func (img *DockerImage) Scan(src interface{}) error {
+	input, err := scanSQLText(src, "DockerImage")
+	if err != nil {
+		return maskAny(err)
+	}
+	return img.UnmarshalText([]byte(input))
+}
+
+// Value implements driver.Valuer, storing the image as string.
+func (img DockerImage) Value() (driver.Value, error) {
+	if err := img.Validate(); err != nil {
+		return nil, err
+	}
+	return img.String(), nil
+}
+
+// Scan implements sql.Scanner. Use NullDockerPort for nullable columns.
+func (d *DockerPort) Scan(src interface{}) error {
+	input, err := scanSQLText(src, "DockerPort")
+	if err != nil {
+		return maskAny(err)
+	}
+	return d.UnmarshalText([]byte(input))
+}
+
+// Value implements driver.Valuer, storing the port as "<port>/<protocol>".
+func (d DockerPort) Value() (driver.Value, error) {
+	if err := d.Validate(); err != nil {
+		return nil, err
+	}
+	return d.String(), nil
+}
+
+// Scan implements sql.Scanner. Use NullDomain for nullable columns.
+func (d *Domain) Scan(src interface{}) error {
+	input, err := scanSQLText(src, "Domain")
+	if err != nil {
+		return maskAny(err)
+	}
+	return d.UnmarshalText([]byte(input))
+}
+
+// Value implements driver.Valuer, storing the domain as string.
+func (d Domain) Value() (driver.Value, error) {
+	if err := d.Validate(); err != nil {
+		return nil, err
+	}
+	return string(d), nil
+}
+
+// NullDockerImage is a DockerImage that may be NULL, like sql.NullString.
+type NullDockerImage struct {
+	Image DockerImage
+	Valid bool // Valid is true if Image is not NULL
+}
+
+// Scan implements sql.Scanner.
+func (n *NullDockerImage) Scan(src interface{}) error {
+	if src == nil {
+		*n = NullDockerImage{}
+		return nil
+	}
+	if err := n.Image.Scan(src); err != nil {
+		return err
+	}
+	n.Valid = true
+	return nil
+}
+
+// Value implements driver.Valuer.
+func (n NullDockerImage) Value() (driver.Value, error) {
+	if !n.Valid {
+		return nil, nil
+	}
+	return n.Image.Value()
+}
+
+// NullDockerPort is a DockerPort that may be NULL, like sql.NullString.
+type NullDockerPort struct {
+	Port  DockerPort
+	Valid bool // Valid is true if Port is not NULL
+}
+
+// Scan implements sql.Scanner.
+func (n *NullDockerPort) Scan(src interface{}) error {
+	if src == nil {
+		*n = NullDockerPort{}
+		return nil
+	}
+	if err := n.Port.Scan(src); err != nil {
+		return err
+	}
+	n.Valid = true
+	return nil
+}
+
+// Value implements driver.Valuer.
+func (n NullDockerPort) Value() (driver.Value, error) {
+	if !n.Valid {
+		return nil, nil
+	}
+	return n.Port.Value()
+}
+
+// NullDomain is a Domain that may be NULL, like sql.NullString.
+type NullDomain struct {
+	Domain Domain
+	Valid  bool // Valid is true if Domain is not NULL
+}
+
+// Scan implements sql.Scanner.
+func (n *NullDomain) Scan(src interface{}) error {
+	if src == nil {
+		*n = NullDomain{}
+		return nil
+	}
+	if err := n.Domain.Scan(src); err != nil {
+		return err
+	}
+	n.Valid = true
+	return nil
+}
+
+// Value implements driver.Valuer.
+func (n NullDomain) Value() (driver.Value, error) {
+	if !n.Valid {
+		return nil, nil
+	}
+	return n.Domain.Value()
+}
+
+// scanSQLText returns the text of a string or []byte driver value.
+func scanSQLText(src interface{}, typeName string) (string, error) {
+	switch src := src.(type) {
+	case string:
+		return src, nil
+	case []byte:
+		return string(src), nil
+	case nil:
+		return "", errgo.Newf("Cannot scan NULL into %s, use Null%s instead", typeName, typeName)
+	default:
+		return "", errgo.Newf("Cannot scan %T into %s", src, typeName)
+	}
+}
@@ -0,0 +1,137 @@
+package generictypes
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"testing"
+)
+
+var (
+	_ sql.Scanner   = &DockerImage{}
+	_ sql.Scanner   = &DockerPort{}
+	_ sql.Scanner   = new(Domain)
+	_ sql.Scanner   = &NullDockerImage{}
+	_ sql.Scanner   = &NullDockerPort{}
+	_ sql.Scanner   = &NullDomain{}
+	_ driver.Valuer = DockerImage{}
+	_ driver.Valuer = DockerPort{}
+	_ driver.Valuer = Domain("")
+	_ driver.Valuer = NullDockerImage{}
+	_ driver.Valuer = NullDockerPort{}
+	_ driver.Valuer = NullDomain{}
+)
+
+var sqlScanInput = []struct {
+	Src      interface{}
+	Expected string
+}{
+	{"redis:3.0", "redis:3.0"},
+	{[]byte("quay.io/giantswarm/app:1.0"), "quay.io/giantswarm/app:1.0"},
+}
+
+func TestDockerImage_Scan(t *testing.T) {
+	for _, data := range sqlScanInput {
+		var img DockerImage
+		if err := img.Scan(data.Src); err != nil {
+			t.Fatalf("Expected no error for %#v, got %v", data.Src, err)
+		}
+		value, err := img.Value()
+		if err != nil {
+			t.Fatalf("Expected no error for %v, got %v", img, err)
+		}
+		if value != data.Expected {
+			t.Fatalf("Expected '%s', got %#v", data.Expected, value)
+		}
+	}
+}
+
+var invalidSQLScanInput = []struct {
+	Src interface{}
+}{
+	{nil},
+	{42},
+	{""},
+	{"foo/image"},
+	{[]byte("80/icmp")},
+	{"i.$am.invalid.com"},
+}
+
+func TestScanErrors(t *testing.T) {
+	for _, data := range invalidSQLScanInput {
+		var img DockerImage
+		if err := img.Scan(data.Src); err == nil {
+			t.Fatalf("Expected error scanning %#v into image, got %v", data.Src, img)
+		}
+		var port DockerPort
+		if err := port.Scan(data.Src); err == nil {
+			t.Fatalf("Expected error scanning %#v into port, got %v", data.Src, port)
+		}
+		var domain Domain
+		if err := domain.Scan(data.Src); err == nil {
+			t.Fatalf("Expected error scanning %#v into domain, got %v", data.Src, domain)
+		}
+	}
+}
+
+func TestDockerPort_Scan(t *testing.T) {
+	var port DockerPort
+	if err := port.Scan([]byte("443")); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if port != MustParseDockerPort("443/tcp") {
+		t.Fatalf("Expected 443/tcp, got %v", port)
+	}
+	if value, err := port.Value(); err != nil || value != "443/tcp" {
+		t.Fatalf("Expected '443/tcp', got %#v, %v", value, err)
+	}
+
+	if _, err := (DockerPort{}).Value(); err == nil {
+		t.Fatalf("Expected error for empty port")
+	}
+}
+
+func TestDomain_Scan(t *testing.T) {
+	var domain Domain
+	if err := domain.Scan("i.am.correct.com"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if value, err := domain.Value(); err != nil || value != "i.am.correct.com" {
+		t.Fatalf("Expected 'i.am.correct.com', got %#v, %v", value, err)
+	}
+
+	if _, err := Domain("i.$am.invalid.com").Value(); err == nil {
+		t.Fatalf("Expected error for invalid domain")
+	}
+}
+
+func TestNullTypes(t *testing.T) {
+	image := NullDockerImage{Image: MustParseDockerImage("redis"), Valid: true}
+	port := NullDockerPort{Port: MustParseDockerPort("80/tcp"), Valid: true}
+	domain := NullDomain{Domain: "i.am.correct.com", Valid: true}
+
+	for _, scanner := range []sql.Scanner{&image, &port, &domain} {
+		if err := scanner.Scan(nil); err != nil {
+			t.Fatalf("Expected no error scanning NULL, got %v", err)
+		}
+		if value, err := scanner.(driver.Valuer).Value(); err != nil || value != nil {
+			t.Fatalf("Expected NULL, got %#v, %v", value, err)
+		}
+	}
+	if image.Valid || port.Valid || domain.Valid {
+		t.Fatalf("Expected NULL values to be invalid")
+	}
+
+	if err := image.Scan("redis:3.0"); err != nil || !image.Valid || image.Image.Version != "3.0" {
+		t.Fatalf("Unexpected image %#v, %v", image, err)
+	}
+	if err := port.Scan([]byte("53/udp")); err != nil || !port.Valid || port.Port.Protocol != ProtocolUDP {
+		t.Fatalf("Unexpected port %#v, %v", port, err)
+	}
+	if err := domain.Scan("i.am.correct.com"); err != nil || !domain.Valid {
+		t.Fatalf("Unexpected domain %#v, %v", domain, err)
+	}
+
+	if err := port.Scan(42); err == nil {
+		t.Fatalf("Expected error scanning int into port")
+	}
+}
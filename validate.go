@@ -0,0 +1,176 @@
+package generictypes
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Validator is implemented by all types of this package that can check
+// themselves, e.g. DockerImage, DockerPort, Domain and Platform.
+type Validator interface {
+	Validate() error
+}
+
+var validatorType = reflect.TypeOf((*Validator)(nil)).Elem()
+
+// FieldError is the validation error of a single field found by
+// ValidateStruct.
+type FieldError struct {
+	// Path is the path of the field, e.g. "services[2].ports[0]". It uses the
+	// JSON names of fields if they have one.
+	Path string
+	Err  error
+}
+
+func (e FieldError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Path, e.Err)
+}
+
+func (e FieldError) Unwrap() error {
+	return e.Err
+}
+
+// ValidationErrors lists all failures found by ValidateStruct.
+type ValidationErrors []FieldError
+
+func (e ValidationErrors) Error() string {
+	lines := make([]string, len(e))
+	for i, fieldErr := range e {
+		lines[i] = fieldErr.Error()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Unwrap returns all field errors, so that errors.Is and errors.As match if
+// any field matches.
+func (e ValidationErrors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i, fieldErr := range e {
+		errs[i] = fieldErr
+	}
+	return errs
+}
+
+// ValidateStruct walks the given struct, or pointer to a struct, through
+// nested structs, pointers, interfaces, slices, arrays and maps and calls
+// Validate on every value implementing Validator. Values with a Validate
+// method are not walked any further. Zero values are treated as unset and
+// are not validated. Returns nil if all values are valid, or ValidationErrors
+// listing every failure otherwise.
+func ValidateStruct(v interface{}) error {
+	w := structValidator{visited: map[visitedPointer]bool{}}
+	w.walk(reflect.ValueOf(v), "")
+	if len(w.errors) == 0 {
+		return nil
+	}
+	return w.errors
+}
+
+type structValidator struct {
+	errors  ValidationErrors
+	visited map[visitedPointer]bool // pointers already walked, to handle cycles
+}
+
+// visitedPointer identifies a walked pointer. The type is part of the key,
+// since a pointer to a struct and a pointer to its first field share the same
+// address.
+type visitedPointer struct {
+	Type    reflect.Type
+	Address uintptr
+}
+
+func (w *structValidator) walk(value reflect.Value, path string) {
+	if !value.IsValid() || value.IsZero() {
+		return
+	}
+
+	if validator, ok := asValidator(value); ok {
+		if err := validator.Validate(); err != nil {
+			w.errors = append(w.errors, FieldError{Path: path, Err: err})
+		}
+		return
+	}
+
+	switch value.Kind() {
+	case reflect.Ptr:
+		key := visitedPointer{Type: value.Type(), Address: value.Pointer()}
+		if w.visited[key] {
+			return
+		}
+		w.visited[key] = true
+		w.walk(value.Elem(), path)
+	case reflect.Interface:
+		w.walk(value.Elem(), path)
+	case reflect.Struct:
+		for i := 0; i < value.NumField(); i++ {
+			field := value.Type().Field(i)
+			if field.PkgPath != "" {
+				continue // unexported
+			}
+			name := fieldName(field)
+			if name == "" {
+				continue
+			}
+			fieldPath := name
+			if field.Anonymous && field.Tag.Get("json") == "" {
+				fieldPath = path // embedded fields are promoted
+			} else if path != "" {
+				fieldPath = path + "." + name
+			}
+			w.walk(value.Field(i), fieldPath)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			w.walk(value.Index(i), fmt.Sprintf("%s[%d]", path, i))
+		}
+	case reflect.Map:
+		keys := value.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+		})
+		for _, key := range keys {
+			keyPath := fmt.Sprintf("%s[%v]", path, key.Interface())
+			if validator, ok := asValidator(key); ok && !key.IsZero() {
+				if err := validator.Validate(); err != nil {
+					w.errors = append(w.errors, FieldError{Path: keyPath, Err: err})
+				}
+			}
+			w.walk(value.MapIndex(key), keyPath)
+		}
+	}
+}
+
+// asValidator returns the Validator of the given value, also for types like
+// Domain that implement Validate on a pointer receiver.
+func asValidator(value reflect.Value) (Validator, bool) {
+	if value.Kind() == reflect.Interface || !value.CanInterface() {
+		return nil, false
+	}
+	if value.Type().Implements(validatorType) {
+		if value.Kind() == reflect.Ptr && value.IsNil() {
+			return nil, false
+		}
+		return value.Interface().(Validator), true
+	}
+	if reflect.PtrTo(value.Type()).Implements(validatorType) {
+		ptr := reflect.New(value.Type())
+		ptr.Elem().Set(value)
+		return ptr.Interface().(Validator), true
+	}
+	return nil, false
+}
+
+// fieldName returns the JSON name of a struct field, or "" if it is ignored.
+func fieldName(field reflect.StructField) string {
+	tag := strings.Split(field.Tag.Get("json"), ",")[0]
+	switch tag {
+	case "-":
+		return ""
+	case "":
+		return field.Name
+	default:
+		return tag
+	}
+}
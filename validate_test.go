@@ -0,0 +1,150 @@
+package generictypes
+
+import (
+	"strings"
+	"testing"
+)
+
+type testService struct {
+	Name     string            `json:"name"`
+	Image    DockerImage       `json:"image"`
+	Ports    []DockerPort      `json:"ports"`
+	Domains  map[string]Domain `json:"domains,omitempty"`
+	Platform *Platform         `json:"platform,omitempty"`
+	Ignored  DockerPort        `json:"-"`
+	internal DockerPort
+}
+
+type testConfig struct {
+	Services []testService `json:"services"`
+	Default  *testService
+	Extra    interface{} `json:"extra"`
+	Exposed  map[DockerPort]string
+}
+
+func TestValidateStruct_Valid(t *testing.T) {
+	config := testConfig{
+		Services: []testService{
+			{
+				Name:     "app",
+				Image:    MustParseDockerImage("quay.io/giantswarm/app:1.0"),
+				Ports:    []DockerPort{MustParseDockerPort("80"), MustParseDockerPort("53/udp")},
+				Domains:  map[string]Domain{"public": "i.am.correct.com"},
+				Platform: &Platform{OS: "linux", Architecture: "amd64"},
+			},
+			{Name: "optional", Ignored: DockerPort{Port: "0"}, internal: DockerPort{Port: "0"}},
+		},
+		Exposed: map[DockerPort]string{MustParseDockerPort("8080/tcp"): "http"},
+	}
+
+	if err := ValidateStruct(config); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := ValidateStruct(&config); err != nil {
+		t.Fatalf("Expected no error for pointer, got %v", err)
+	}
+}
+
+func TestValidateStruct_Invalid(t *testing.T) {
+	config := testConfig{
+		Services: []testService{
+			{Name: "ok", Image: MustParseDockerImage("redis")},
+			{Name: "ok", Ports: []DockerPort{MustParseDockerPort("80")}},
+			{
+				Name:     "broken",
+				Image:    DockerImage{Repository: "UPPER"},
+				Ports:    []DockerPort{{Port: "0", Protocol: ProtocolTCP}, MustParseDockerPort("81")},
+				Domains:  map[string]Domain{"public": "i.$am.invalid.com"},
+				Platform: &Platform{OS: "linux", Architecture: "aarch64"},
+			},
+		},
+		Default: &testService{Ports: []DockerPort{{Port: "80", Protocol: "icmp"}}},
+		Extra:   []DockerPort{{Port: "99999", Protocol: ProtocolTCP}},
+		Exposed: map[DockerPort]string{{Port: "80", Protocol: "sctp"}: "http"},
+	}
+
+	err := ValidateStruct(config)
+	if err == nil {
+		t.Fatalf("Expected error")
+	}
+	validationErrors, ok := err.(ValidationErrors)
+	if !ok {
+		t.Fatalf("Expected ValidationErrors, got %T", err)
+	}
+
+	var expectedPaths = []string{
+		"services[2].image",
+		"services[2].ports[0]",
+		"services[2].domains[public]",
+		"services[2].platform",
+		"Default.ports[0]",
+		"extra[0]",
+		"Exposed[80/sctp]",
+	}
+	if len(validationErrors) != len(expectedPaths) {
+		t.Fatalf("Expected %d errors, got %v", len(expectedPaths), err)
+	}
+	for i, path := range expectedPaths {
+		if validationErrors[i].Path != path {
+			t.Fatalf("Expected path '%s' at %d, got '%s'", path, i, validationErrors[i].Path)
+		}
+		if !strings.Contains(err.Error(), path+": ") {
+			t.Fatalf("Expected error message to contain '%s', got %v", path, err)
+		}
+	}
+}
+
+type testNode struct {
+	Port DockerPort
+	Next *testNode
+}
+
+func TestValidateStruct_Cycle(t *testing.T) {
+	node := &testNode{Port: DockerPort{Port: "0", Protocol: ProtocolTCP}}
+	node.Next = node
+
+	err := ValidateStruct(node)
+	if err == nil {
+		t.Fatalf("Expected error")
+	}
+	if len(err.(ValidationErrors)) != 1 {
+		t.Fatalf("Expected a single error, got %v", err)
+	}
+}
+
+type testOuter struct {
+	Inner testInner
+}
+
+type testInner struct {
+	Port DockerPort
+}
+
+func TestValidateStruct_PointerToFirstField(t *testing.T) {
+	outer := &testOuter{Inner: testInner{Port: DockerPort{Port: "0", Protocol: ProtocolTCP}}}
+	config := struct {
+		Outer *testOuter
+		Inner *testInner
+	}{outer, &outer.Inner}
+
+	err := ValidateStruct(config)
+	errs, ok := err.(ValidationErrors)
+	if !ok || len(errs) != 2 || errs[0].Path != "Outer.Inner.Port" || errs[1].Path != "Inner.Port" {
+		t.Fatalf("Expected errors for Outer.Inner.Port and Inner.Port, got %v", err)
+	}
+}
+
+func TestValidators(t *testing.T) {
+	var validators = []Validator{
+		DockerImage{},
+		DockerPort{},
+		new(Domain),
+		Platform{},
+	}
+
+	for _, validator := range validators {
+		if err := validator.Validate(); err == nil {
+			t.Fatalf("Expected error for zero %T", validator)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package generictypes
+
+import (
+	"github.com/juju/errgo"
+	"gopkg.in/yaml.v3"
+
+	"bytes"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// yamlEdit replaces the bytes between Start and End of a YAML source.
+type yamlEdit struct {
+	Start, End int
+	Text       string
+}
+
+// applyYAMLEdits applies non-overlapping edits to the source. Edits are
+// applied from the end of the source, so that earlier offsets stay valid.
+func applyYAMLEdits(source []byte, edits []yamlEdit) []byte {
+	sort.Slice(edits, func(i, j int) bool { return edits[i].Start > edits[j].Start })
+
+	for _, edit := range edits {
+		result := make([]byte, 0, len(source)-(edit.End-edit.Start)+len(edit.Text))
+		result = append(result, source[:edit.Start]...)
+		result = append(result, edit.Text...)
+		source = append(result, source[edit.End:]...)
+	}
+	return source
+}
+
+// yamlReplaceScalar returns the edit replacing the value of a single line
+// scalar node, keeping its quoting style where possible.
+func yamlReplaceScalar(source []byte, node *yaml.Node, value string) (yamlEdit, error) {
+	start, end, err := yamlScalarRange(source, node)
+	if err != nil {
+		return yamlEdit{}, err
+	}
+	return yamlEdit{Start: start, End: end, Text: yamlScalarWithStyle(value, node.Style&^yaml.TaggedStyle)}, nil
+}
+
+// yamlInsertKey returns the edit inserting "<key>: <value>" as first key of a
+// block mapping, indented like the existing keys.
+func yamlInsertKey(source []byte, mapping *yaml.Node, key, value string) (yamlEdit, error) {
+	if mapping.Kind != yaml.MappingNode || len(mapping.Content) == 0 || mapping.Style == yaml.FlowStyle {
+		return yamlEdit{}, errgo.Newf("Line %d: Keys can only be inserted into non-empty block mappings", mapping.Line)
+	}
+
+	firstKey := mapping.Content[0]
+	lineStart := yamlLineOffset(source, firstKey.Line)
+	keyStart := yamlColumnOffset(source, firstKey.Line, firstKey.Column)
+	text := strings.Repeat(" ", firstKey.Column-1) + key + ": " + yamlScalarWithStyle(value, 0) + "\n"
+
+	// The first key of a mapping in a sequence shares its line with the dash,
+	// e.g. "- name: foo", so insert after the dash instead.
+	if prefix := string(source[lineStart:keyStart]); strings.TrimSpace(prefix) != "" {
+		start := keyStart
+		text = key + ": " + yamlScalarWithStyle(value, 0) + "\n" + strings.Repeat(" ", firstKey.Column-1)
+		return yamlEdit{Start: start, End: start, Text: text}, nil
+	}
+	return yamlEdit{Start: lineStart, End: lineStart, Text: text}, nil
+}
+
+// yamlScalarRange returns the byte range of the value of a single line scalar
+// node in the source, including its quotes, but without its anchor and tag.
+func yamlScalarRange(source []byte, node *yaml.Node) (int, int, error) {
+	start := yamlColumnOffset(source, node.Line, node.Column)
+	if node.Kind != yaml.ScalarNode || start >= len(source) {
+		return 0, 0, errgo.Newf("Line %d: Expected a scalar value", node.Line)
+	}
+
+	// The node starts at its anchor or tag, e.g. "&image nginx" or "!!str 1".
+	for start < len(source) && (source[start] == '&' || source[start] == '!') {
+		for start < len(source) && source[start] != ' ' && source[start] != '\t' && source[start] != '\n' {
+			start++
+		}
+		for start < len(source) && (source[start] == ' ' || source[start] == '\t') {
+			start++
+		}
+	}
+
+	lineEnd := bytes.IndexByte(source[start:], '\n')
+	if lineEnd < 0 {
+		lineEnd = len(source)
+	} else {
+		lineEnd += start
+	}
+	line := source[start:lineEnd]
+
+	switch node.Style &^ yaml.TaggedStyle {
+	case yaml.DoubleQuotedStyle:
+		for i := 1; i < len(line); i++ {
+			if line[i] == '\\' {
+				i++
+			} else if line[i] == '"' {
+				return start, start + i + 1, nil
+			}
+		}
+	case yaml.SingleQuotedStyle:
+		for i := 1; i < len(line); i++ {
+			if line[i] == '\'' {
+				if i+1 < len(line) && line[i+1] == '\'' {
+					i++
+					continue
+				}
+				return start, start + i + 1, nil
+			}
+		}
+	case 0:
+		// Single line plain scalars are written as is, so the value ends the
+		// range. This stops before comments as well as before the ",", "}" or
+		// "]" following the value in flow collections.
+		if !strings.Contains(node.Value, "\n") && bytes.HasPrefix(line, []byte(node.Value)) {
+			return start, start + len(node.Value), nil
+		}
+	}
+
+	return 0, 0, errgo.Newf("Line %d: Only single line scalars can be rewritten", node.Line)
+}
+
+// yamlScalarWithStyle formats the value as a scalar in the given style. Plain
+// values that would not be read back as a string, like "" or "1.20", or that
+// contain flow indicators, are double quoted.
+func yamlScalarWithStyle(value string, style yaml.Style) string {
+	switch style {
+	case yaml.DoubleQuotedStyle:
+		return strconv.Quote(value)
+	case yaml.SingleQuotedStyle:
+		return "'" + strings.Replace(value, "'", "''", -1) + "'"
+	}
+
+	var decoded interface{}
+	if strings.ContainsAny(value, ",[]{}") {
+		return strconv.Quote(value)
+	}
+	if err := yaml.Unmarshal([]byte(value), &decoded); err != nil || decoded != value {
+		return strconv.Quote(value)
+	}
+	return value
+}
+
+// yamlLineOffset returns the byte offset of the given 1-based line.
+func yamlLineOffset(source []byte, line int) int {
+	offset := 0
+	for i := 1; i < line; i++ {
+		next := bytes.IndexByte(source[offset:], '\n')
+		if next < 0 {
+			return len(source)
+		}
+		offset += next + 1
+	}
+	return offset
+}
+
+// yamlColumnOffset returns the byte offset of the given 1-based line and
+// column. yaml.v3 counts columns in runes, so multi-byte characters earlier on
+// the line are skipped as a whole.
+func yamlColumnOffset(source []byte, line, column int) int {
+	offset := yamlLineOffset(source, line)
+	for i := 1; i < column && offset < len(source) && source[offset] != '\n'; i++ {
+		_, size := utf8.DecodeRune(source[offset:])
+		offset += size
+	}
+	return offset
+}